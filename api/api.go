@@ -2,10 +2,13 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/ynoproject/wikiwrapper/common"
 	"github.com/ynoproject/wikiwrapper/setup"
@@ -25,10 +28,19 @@ func Init() {
 
 	http.HandleFunc("/locations", handleLocations)
 	http.HandleFunc("/connections", handleConnections)
-	http.HandleFunc("/authors", handleAuthors)
+	http.HandleFunc("/authors", requireFeature(setup.FeatureAuthors, handleAuthors))
+	http.HandleFunc("/author", requireFeature(setup.FeatureAuthors, handleAuthor))
 	http.HandleFunc("/maps", handleMaps)
-	http.HandleFunc("/vms", handleVendingMachines)
-	http.HandleFunc("/images", handleImages)
+	http.HandleFunc("/vms", requireFeature(setup.FeatureVendingMachines, handleVendingMachines))
+	http.HandleFunc("/images", requireFeature(setup.FeatureImages, handleImages))
+	http.HandleFunc("/graph/analysis", handleGraphAnalysis)
+	http.HandleFunc("/graph/export", handleGraphExport)
+	http.HandleFunc("/graph/render.svg", handleGraphRender)
+	http.HandleFunc("/lint", handleLint)
+	http.HandleFunc("/games", handleGames)
+	http.HandleFunc("/search", handleSearch)
+	http.HandleFunc("/changelog", handleChangelog)
+	http.HandleFunc("/bgms", handleBGMs)
 
 	configMiddleware := setup.WikiConfigHandlerMiddleware(wikiConfig)
 	corsHandler := setup.CorsHandlerMiddleware(corsConfig)
@@ -54,11 +66,72 @@ func getListener() net.Listener {
 	return listener
 }
 
+// requireFeature only lets requests through to the handler when the game they
+// target is configured and has the given dataset enabled.
+func requireFeature(feature string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		config := r.Context().Value(setup.ConfigKey).(setup.WikiConfig)
+		gameParam := r.URL.Query().Get("game")
+		if len(gameParam) == 0 {
+			writeError(w, common.NewError(common.ErrMissingParameter, "game not specified"))
+			return
+		}
+
+		game, ok := config.Games[gameParam]
+		if !ok || !game.HasFeature(feature) {
+			writeError(w, common.NewError(common.ErrGameNotSupported, "game not supported"))
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// writeJSON writes v as the JSON response, keeping only the fields listed in
+// the fields parameter when the request has one. The fields apply to the
+// elements of the collection member of v, or to v itself when collection is
+// empty.
+func writeJSON(w http.ResponseWriter, r *http.Request, v interface{}, collection string) {
+	responseJson, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, &common.Error{Code: common.ErrInternal, Message: err.Error(), Err: err})
+		return
+	}
+
+	if fields := parseFields(r.URL.Query().Get("fields")); fields != nil {
+		responseJson, err = pruneJSON(responseJson, fields, collection)
+		if err != nil {
+			writeError(w, &common.Error{Code: common.ErrInternal, Message: err.Error(), Err: err})
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseJson)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	apiError := common.AsError(err)
+	if apiError.Status() >= http.StatusInternalServerError {
+		log.Print("SERVER", apiError.Code, err.Error())
+	}
+
+	responseJson, err := json.Marshal(common.ErrorResponse{Error: apiError})
+	if err != nil {
+		http.Error(w, apiError.Message, apiError.Status())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiError.Status())
+	w.Write(responseJson)
+}
+
 func handleLocations(w http.ResponseWriter, r *http.Request) {
 	config := r.Context().Value(setup.ConfigKey).(setup.WikiConfig)
 	gameParam := r.URL.Query().Get("game")
 	if gameParam == "" {
-		http.Error(w, "game not specified", http.StatusBadRequest)
+		writeError(w, common.NewError(common.ErrMissingParameter, "game not specified"))
 		return
 	}
 
@@ -73,27 +146,87 @@ func handleLocations(w http.ResponseWriter, r *http.Request) {
 		gameParams.ContinueKey = continueKeyParam
 	}
 
-	locations, err := common.GetLocations(gameParams, config)
+	gameParams.Lenient = r.URL.Query().Get("lenient") == "true"
+
+	filter, err := parseLocationFilter(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	locationsJson, err := json.Marshal(locations)
+	var locations *common.Locations
+	if filter.IsEmpty() {
+		locations, err = common.GetLocations(gameParams, config)
+	} else {
+		locations, err = common.GetFilteredLocations(gameParams, filter, config)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(locationsJson)
+	writeJSON(w, r, locations, "locations")
+}
+
+// parseLocationFilter reads the filter and sort parameters of /locations.
+func parseLocationFilter(r *http.Request) (filter common.LocationFilter, err error) {
+	query := r.URL.Query()
+	filter.Author = query.Get("author")
+	filter.VersionAddedFrom = query.Get("versionAddedFrom")
+	filter.VersionAddedTo = query.Get("versionAddedTo")
+	filter.VersionRemovedFrom = query.Get("versionRemovedFrom")
+	filter.VersionRemovedTo = query.Get("versionRemovedTo")
+	filter.AsOfVersion = query.Get("asOfVersion")
+	filter.Sort = query.Get("sort")
+
+	if filter.HasBGM, err = parseBoolParam(r, "hasBgm"); err != nil {
+		return filter, err
+	}
+
+	if filter.HasMap, err = parseBoolParam(r, "hasMap"); err != nil {
+		return filter, err
+	}
+
+	if filter.Removed, err = parseBoolParam(r, "removed"); err != nil {
+		return filter, err
+	}
+
+	mapIdParam := query.Get("mapId")
+	if len(mapIdParam) != 0 {
+		mapId, err := strconv.Atoi(mapIdParam)
+		if err != nil {
+			return filter, common.NewError(common.ErrInvalidParameter, "mapId must be a number")
+		}
+		filter.MapId = &mapId
+	}
+
+	return filter, nil
+}
+
+// parseBoolParam reads an optional true/false parameter, returning nil when it
+// is absent.
+func parseBoolParam(r *http.Request, name string) (*bool, error) {
+	param := r.URL.Query().Get(name)
+	switch param {
+	case "":
+		return nil, nil
+	case "true", "false":
+		value := param == "true"
+		return &value, nil
+	}
+
+	return nil, &common.Error{
+		Code:     common.ErrInvalidParameter,
+		Message:  name + " must be true or false",
+		Accepted: []string{"true", "false"},
+	}
 }
 
 func handleImages(w http.ResponseWriter, r *http.Request) {
 	config := r.Context().Value(setup.ConfigKey).(setup.WikiConfig)
 	gameParam := r.URL.Query().Get("game")
 	if gameParam == "" {
-		http.Error(w, "game not specified", http.StatusBadRequest)
+		writeError(w, common.NewError(common.ErrMissingParameter, "game not specified"))
 		return
 	}
 
@@ -105,25 +238,18 @@ func handleImages(w http.ResponseWriter, r *http.Request) {
 
 	images, err := common.GetImages(gameParams, config)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	imagesJson, err := json.Marshal(images)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(imagesJson)
+	writeJSON(w, r, images, "locationImages")
 }
 
 func handleConnections(w http.ResponseWriter, r *http.Request) {
 	config := r.Context().Value(setup.ConfigKey).(setup.WikiConfig)
 	gameParam := r.URL.Query().Get("game")
 	if gameParam == "" {
-		http.Error(w, "game not specified", http.StatusBadRequest)
+		writeError(w, common.NewError(common.ErrMissingParameter, "game not specified"))
 		return
 	}
 
@@ -138,101 +264,382 @@ func handleConnections(w http.ResponseWriter, r *http.Request) {
 		gameParams.ContinueKey = continueKeyParam
 	}
 
-	connections, err := common.GetConnections(gameParams, config)
+	gameParams.Lenient = r.URL.Query().Get("lenient") == "true"
+
+	filter := common.ConnectionFilter{
+		Attribute:   r.URL.Query().Get("attribute"),
+		Season:      r.URL.Query().Get("season"),
+		Effect:      r.URL.Query().Get("effect"),
+		Origin:      r.URL.Query().Get("origin"),
+		Destination: r.URL.Query().Get("destination"),
+		AsOfVersion: r.URL.Query().Get("asOfVersion"),
+	}
+
+	versionParam := r.URL.Query().Get("version")
+	if versionParam != "" && versionParam != "1" && versionParam != "2" {
+		writeError(w, &common.Error{
+			Code:     common.ErrInvalidParameter,
+			Message:  "version not supported",
+			Accepted: []string{"1", "2"},
+		})
+		return
+	}
+
+	includeRemoved, err := parseBoolParam(r, "includeRemoved")
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
+	filter.IncludeRemoved = includeRemoved
 
-	connectionsJson, err := json.Marshal(connections)
+	var connections *common.Connections
+	if filter.IsEmpty() {
+		connections, err = common.GetConnections(gameParams, config)
+	} else {
+		connections, err = common.GetFilteredConnections(gameParams, filter, config)
+	}
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(connectionsJson)
+	if versionParam == "2" {
+		writeJSON(w, r, common.TypeConnections(connections, config), "connections")
+		return
+	}
+
+	writeJSON(w, r, connections, "connections")
 }
 
 func handleAuthors(w http.ResponseWriter, r *http.Request) {
 	config := r.Context().Value(setup.ConfigKey).(setup.WikiConfig)
 	gameParam := r.URL.Query().Get("game")
 	if len(gameParam) == 0 {
-		http.Error(w, "game not specified", http.StatusBadRequest)
+		writeError(w, common.NewError(common.ErrMissingParameter, "game not specified"))
 		return
 	}
 
-	if (gameParam != "2kki") && (gameParam != "unevendream") && (gameParam != "unconscious") {
-		http.Error(w, "game not supported", http.StatusBadRequest)
+	includeLocations, err := parseBoolParam(r, "includeLocations")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if includeLocations != nil && *includeLocations {
+		authorLocations, err := common.GetAuthorLocations(gameParam, config)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, r, authorLocations, "")
 		return
 	}
 
 	authors, err := common.GetAuthors(gameParam, config)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	authorsJson, err := json.Marshal(authors)
+	writeJSON(w, r, authors, "")
+}
+
+func handleAuthor(w http.ResponseWriter, r *http.Request) {
+	config := r.Context().Value(setup.ConfigKey).(setup.WikiConfig)
+	gameParam := r.URL.Query().Get("game")
+	if len(gameParam) == 0 {
+		writeError(w, common.NewError(common.ErrMissingParameter, "game not specified"))
+		return
+	}
+
+	nameParam := r.URL.Query().Get("name")
+	if len(nameParam) == 0 {
+		writeError(w, common.NewError(common.ErrMissingParameter, "name not specified"))
+		return
+	}
+
+	author, err := common.GetAuthor(gameParam, nameParam, config)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(authorsJson)
+	writeJSON(w, r, author, "")
 }
 
 func handleMaps(w http.ResponseWriter, r *http.Request) {
 	config := r.Context().Value(setup.ConfigKey).(setup.WikiConfig)
 	gameParam := r.URL.Query().Get("game")
 	if len(gameParam) == 0 {
-		http.Error(w, "game not specified", http.StatusBadRequest)
+		writeError(w, common.NewError(common.ErrMissingParameter, "game not specified"))
 		return
 	}
 
 	locationParam := r.URL.Query().Get("location")
 	if len(locationParam) == 0 {
-		http.Error(w, "location not specified", http.StatusBadRequest)
+		catalog, err := common.GetMapCatalog(gameParam, config)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, r, catalog, "locations")
 		return
 	}
 
 	maps, err := common.GetMaps(gameParam, locationParam, config)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	mapsJson, err := json.Marshal(maps)
+	writeJSON(w, r, maps, "")
+}
+
+func handleVendingMachines(w http.ResponseWriter, r *http.Request) {
+	config := r.Context().Value(setup.ConfigKey).(setup.WikiConfig)
+	gameParam := r.URL.Query().Get("game")
+	if len(gameParam) == 0 {
+		writeError(w, common.NewError(common.ErrMissingParameter, "game not specified"))
+		return
+	}
+
+	vms, err := common.GetVendingMachines(gameParam, config)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(mapsJson)
+	writeJSON(w, r, vms, "")
 }
 
-func handleVendingMachines(w http.ResponseWriter, r *http.Request) {
+func handleGraphAnalysis(w http.ResponseWriter, r *http.Request) {
 	config := r.Context().Value(setup.ConfigKey).(setup.WikiConfig)
 	gameParam := r.URL.Query().Get("game")
 	if len(gameParam) == 0 {
-		http.Error(w, "game not specified", http.StatusBadRequest)
+		writeError(w, common.NewError(common.ErrMissingParameter, "game not specified"))
 		return
 	}
 
-	vms, err := common.GetVendingMachines(gameParam, config)
+	game, ok := config.Games[gameParam]
+	if !ok {
+		writeError(w, common.NewError(common.ErrGameNotSupported, "game not supported"))
+		return
+	}
+
+	hubParam := r.URL.Query().Get("hub")
+	if len(hubParam) == 0 {
+		hubParam = game.Hub
+	}
+
+	if len(hubParam) == 0 {
+		writeError(w, common.NewError(common.ErrMissingParameter, "hub not specified"))
+		return
+	}
+
+	graph, err := common.GetGraph(gameParam, config)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	if _, ok := graph.Locations[hubParam]; !ok {
+		writeError(w, common.NewError(common.ErrNotFound, "hub not found"))
+		return
+	}
+
+	writeJSON(w, r, graph.Analyze(hubParam), "")
+}
+
+func handleGraphExport(w http.ResponseWriter, r *http.Request) {
+	config := r.Context().Value(setup.ConfigKey).(setup.WikiConfig)
+	gameParam := r.URL.Query().Get("game")
+	if len(gameParam) == 0 {
+		writeError(w, common.NewError(common.ErrMissingParameter, "game not specified"))
+		return
+	}
+
+	formatParam := r.URL.Query().Get("format")
+	if len(formatParam) == 0 {
+		formatParam = "cytoscape"
+	}
+
+	if formatParam != "dot" && formatParam != "graphml" && formatParam != "cytoscape" {
+		writeError(w, &common.Error{
+			Code:     common.ErrInvalidParameter,
+			Message:  "format not supported",
+			Accepted: []string{"dot", "graphml", "cytoscape"},
+		})
+		return
+	}
+
+	graph, err := common.GetGraph(gameParam, config)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+
+	switch formatParam {
+	case "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		w.Write([]byte(graph.ExportDOT()))
+	case "graphml":
+		graphML, err := graph.ExportGraphML()
+		if err != nil {
+			writeError(w, &common.Error{Code: common.ErrInternal, Message: err.Error(), Err: err})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/graphml+xml")
+		w.Write(graphML)
+	case "cytoscape":
+		writeJSON(w, r, graph.ExportCytoscape(), "")
+	}
+}
+
+func handleGraphRender(w http.ResponseWriter, r *http.Request) {
+	config := r.Context().Value(setup.ConfigKey).(setup.WikiConfig)
+	gameParam := r.URL.Query().Get("game")
+	if len(gameParam) == 0 {
+		writeError(w, common.NewError(common.ErrMissingParameter, "game not specified"))
 		return
 	}
 
-	vmsJson, err := json.Marshal(vms)
+	game, ok := config.Games[gameParam]
+	if !ok {
+		writeError(w, common.NewError(common.ErrGameNotSupported, "game not supported"))
+		return
+	}
+
+	centerParam := r.URL.Query().Get("center")
+	if len(centerParam) == 0 {
+		centerParam = game.Hub
+	}
+
+	if len(centerParam) == 0 {
+		writeError(w, common.NewError(common.ErrMissingParameter, "center not specified"))
+		return
+	}
+
+	depth := 2
+	depthParam := r.URL.Query().Get("depth")
+	if len(depthParam) != 0 {
+		parsedDepth, err := strconv.Atoi(depthParam)
+		if err != nil || parsedDepth < 0 || parsedDepth > 5 {
+			writeError(w, common.NewError(common.ErrInvalidParameter, "depth must be a number between 0 and 5"))
+			return
+		}
+		depth = parsedDepth
+	}
+
+	graph, err := common.GetGraph(gameParam, config)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(vmsJson)
+	if _, ok := graph.Locations[centerParam]; !ok {
+		writeError(w, common.NewError(common.ErrNotFound, "location not found"))
+		return
+	}
+
+	subgraph := graph.Subgraph(centerParam, depth)
+	if len(subgraph.Nodes) > common.MaxRenderNodes {
+		writeError(w, common.NewError(common.ErrInvalidParameter, fmt.Sprintf("the subgraph has %d locations, more than the %d that can be rendered; use a lower depth", len(subgraph.Nodes), common.MaxRenderNodes)))
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Write([]byte(subgraph.RenderSVG()))
+}
+
+func handleLint(w http.ResponseWriter, r *http.Request) {
+	config := r.Context().Value(setup.ConfigKey).(setup.WikiConfig)
+	gameParam := r.URL.Query().Get("game")
+	if len(gameParam) == 0 {
+		writeError(w, common.NewError(common.ErrMissingParameter, "game not specified"))
+		return
+	}
+
+	report, err := common.Lint(gameParam, config)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, r, report, "issues")
+}
+
+func handleGames(w http.ResponseWriter, r *http.Request) {
+	config := r.Context().Value(setup.ConfigKey).(setup.WikiConfig)
+	writeJSON(w, r, common.GetGames(config), "")
+}
+
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+	config := r.Context().Value(setup.ConfigKey).(setup.WikiConfig)
+	gameParam := r.URL.Query().Get("game")
+	if len(gameParam) == 0 {
+		writeError(w, common.NewError(common.ErrMissingParameter, "game not specified"))
+		return
+	}
+
+	queryParam := r.URL.Query().Get("q")
+	if len(strings.TrimSpace(queryParam)) == 0 {
+		writeError(w, common.NewError(common.ErrMissingParameter, "q not specified"))
+		return
+	}
+
+	limit := 20
+	limitParam := r.URL.Query().Get("limit")
+	if len(limitParam) != 0 {
+		parsedLimit, err := strconv.Atoi(limitParam)
+		if err != nil || parsedLimit < 1 || parsedLimit > 100 {
+			writeError(w, common.NewError(common.ErrInvalidParameter, "limit must be a number between 1 and 100"))
+			return
+		}
+		limit = parsedLimit
+	}
+
+	results, err := common.Search(gameParam, queryParam, limit, config)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, r, results, "results")
+}
+
+func handleChangelog(w http.ResponseWriter, r *http.Request) {
+	config := r.Context().Value(setup.ConfigKey).(setup.WikiConfig)
+	gameParam := r.URL.Query().Get("game")
+	if len(gameParam) == 0 {
+		writeError(w, common.NewError(common.ErrMissingParameter, "game not specified"))
+		return
+	}
+
+	changelog, err := common.GetChangelog(gameParam, r.URL.Query().Get("from"), r.URL.Query().Get("to"), config)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, r, changelog, "versions")
+}
+
+func handleBGMs(w http.ResponseWriter, r *http.Request) {
+	config := r.Context().Value(setup.ConfigKey).(setup.WikiConfig)
+	gameParam := r.URL.Query().Get("game")
+	if len(gameParam) == 0 {
+		writeError(w, common.NewError(common.ErrMissingParameter, "game not specified"))
+		return
+	}
+
+	catalog, err := common.GetBGMCatalog(gameParam, config)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, r, catalog, "bgms")
 }
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// fieldSet is a tree of requested JSON fields. A nil subtree keeps the whole
+// value of the field.
+type fieldSet map[string]fieldSet
+
+// parseFields reads a fields parameter such as "title,mapIds,bgms.path".
+// It returns nil when no field is requested.
+func parseFields(param string) fieldSet {
+	var fields fieldSet
+	for _, path := range strings.Split(param, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		if fields == nil {
+			fields = fieldSet{}
+		}
+
+		current := fields
+		names := strings.Split(path, ".")
+		for i, name := range names {
+			subfields, seen := current[name]
+			if i == len(names)-1 {
+				// Requesting a field keeps all of it, even when some of its
+				// subfields were requested too.
+				current[name] = nil
+				break
+			}
+
+			if seen && subfields == nil {
+				break
+			}
+			if subfields == nil {
+				subfields = fieldSet{}
+				current[name] = subfields
+			}
+			current = subfields
+		}
+	}
+	return fields
+}
+
+// pruneJSON keeps only the requested fields of a response. Fields are
+// relative to the elements of the collection member of the response, so
+// "title" with collection "locations" keeps the title of each location of a
+// /locations response while leaving other members, such as warnings, as they
+// are. Without a collection, fields apply to the response itself, or to each
+// of its elements when it is an array.
+func pruneJSON(data []byte, fields fieldSet, collection string) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	if object, ok := value.(map[string]interface{}); ok && collection != "" {
+		pruneValue(object[collection], fields)
+	} else {
+		pruneValue(value, fields)
+	}
+
+	return json.Marshal(value)
+}
+
+// pruneValue removes the fields that were not requested from objects,
+// descending into arrays. Other values are kept as they are.
+func pruneValue(value interface{}, fields fieldSet) {
+	if fields == nil {
+		return
+	}
+
+	switch value := value.(type) {
+	case []interface{}:
+		for _, element := range value {
+			pruneValue(element, fields)
+		}
+	case map[string]interface{}:
+		for name, member := range value {
+			subfields, ok := fields[name]
+			if !ok {
+				delete(value, name)
+				continue
+			}
+			pruneValue(member, subfields)
+		}
+	}
+}
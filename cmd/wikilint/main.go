@@ -0,0 +1,44 @@
+// Command wikilint prints the data consistency report of a game as JSON,
+// the same report served by the /lint endpoint. It exits with status 1 when
+// issues are found or pages could not be parsed.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/ynoproject/wikiwrapper/common"
+	"github.com/ynoproject/wikiwrapper/setup"
+)
+
+func main() {
+	configPath := flag.String("config", "wiki_config.yml", "path to the wiki config")
+	gameCode := flag.String("game", "", "game code to lint")
+	flag.Parse()
+
+	if *gameCode == "" {
+		log.Fatal("game not specified")
+	}
+
+	wikiConfig, err := setup.LoadWikiConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Error loading wiki config: %v", err)
+	}
+
+	report, err := common.Lint(*gameCode, wikiConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Fatal(err)
+	}
+
+	if len(report.Issues) > 0 || len(report.Warnings) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,106 @@
+package common
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ynoproject/wikiwrapper/setup"
+)
+
+// AuthorLocations is an author with the locations they made. Missing is set
+// for authors credited on locations but absent from the game's author list.
+type AuthorLocations struct {
+	Name                 string   `json:"name"`
+	OriginalName         string   `json:"originalName,omitempty"`
+	PrimaryLocations     []string `json:"primaryLocations"`
+	ContributedLocations []string `json:"contributedLocations"`
+	PrimaryCount         int      `json:"primaryCount"`
+	ContributedCount     int      `json:"contributedCount"`
+	Missing              bool     `json:"missing,omitempty"`
+}
+
+// JoinAuthorLocations lists the primary and contributed locations of every
+// author, matching names case-insensitively. Authors are ordered by name and
+// their locations by title.
+func JoinAuthorLocations(authors []*Author, locations []*Location) []*AuthorLocations {
+	authorsByName := map[string]*AuthorLocations{}
+	authorFor := func(name string, missing bool) *AuthorLocations {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if author, ok := authorsByName[key]; ok {
+			return author
+		}
+
+		author := &AuthorLocations{
+			Name:                 strings.TrimSpace(name),
+			PrimaryLocations:     []string{},
+			ContributedLocations: []string{},
+			Missing:              missing,
+		}
+		authorsByName[key] = author
+		return author
+	}
+
+	for _, author := range authors {
+		authorFor(author.Name, false).OriginalName = author.OriginalName
+	}
+
+	for _, location := range locations {
+		if location.PrimaryAuthor != "" {
+			for _, name := range strings.Split(location.PrimaryAuthor, ", ") {
+				author := authorFor(name, true)
+				author.PrimaryLocations = append(author.PrimaryLocations, location.Title)
+			}
+		}
+
+		for _, name := range location.ContributingAuthors {
+			author := authorFor(name, true)
+			author.ContributedLocations = append(author.ContributedLocations, location.Title)
+		}
+	}
+
+	authorLocations := make([]*AuthorLocations, 0, len(authorsByName))
+	for _, author := range authorsByName {
+		sort.Strings(author.PrimaryLocations)
+		sort.Strings(author.ContributedLocations)
+		author.PrimaryCount = len(author.PrimaryLocations)
+		author.ContributedCount = len(author.ContributedLocations)
+		authorLocations = append(authorLocations, author)
+	}
+
+	sort.Slice(authorLocations, func(i, j int) bool {
+		return strings.ToLower(authorLocations[i].Name) < strings.ToLower(authorLocations[j].Name)
+	})
+
+	return authorLocations
+}
+
+// GetAuthorLocations lists every author of a game with their locations.
+func GetAuthorLocations(gameCode string, wikiConfig setup.WikiConfig) (authorLocations []*AuthorLocations, err error) {
+	authors, err := GetAuthors(gameCode, wikiConfig)
+	if err != nil {
+		return authorLocations, err
+	}
+
+	locations, _, err := CachedLocations(gameCode, wikiConfig)
+	if err != nil {
+		return authorLocations, err
+	}
+
+	return JoinAuthorLocations(authors, locations), nil
+}
+
+// GetAuthor returns one author of a game with their locations.
+func GetAuthor(gameCode string, name string, wikiConfig setup.WikiConfig) (author *AuthorLocations, err error) {
+	authorLocations, err := GetAuthorLocations(gameCode, wikiConfig)
+	if err != nil {
+		return author, err
+	}
+
+	for _, authorLocation := range authorLocations {
+		if strings.EqualFold(authorLocation.Name, strings.TrimSpace(name)) {
+			return authorLocation, nil
+		}
+	}
+
+	return author, NewError(ErrNotFound, "author not found")
+}
@@ -0,0 +1,89 @@
+package common
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ynoproject/wikiwrapper/setup"
+)
+
+// BGMTrack is a BGM with every location it plays in.
+type BGMTrack struct {
+	Path      string   `json:"path"`
+	Title     string   `json:"title"`
+	Label     string   `json:"label,omitempty"`
+	Locations []string `json:"locations"`
+}
+
+type BGMCatalog struct {
+	Game string      `json:"game"`
+	BGMs []*BGMTrack `json:"bgms"`
+}
+
+// bgmKey identifies a track by its file, or by its title and label when the
+// wiki does not give a path.
+func bgmKey(bgm *BGM) string {
+	if path := strings.TrimSpace(bgm.Path); path != "" {
+		return "path:" + path
+	}
+	return "title:" + strings.ToLower(strings.TrimSpace(bgm.Title)) + "\x00" + strings.ToLower(strings.TrimSpace(bgm.Label))
+}
+
+// BuildBGMCatalog deduplicates the BGMs of the locations. A track keeps the
+// first title and label found for it. Tracks are ordered by title, then
+// path, and their locations by title.
+func BuildBGMCatalog(gameCode string, locations []*Location) *BGMCatalog {
+	tracks := map[string]*BGMTrack{}
+	for _, location := range locations {
+		for _, bgm := range location.BGMs {
+			key := bgmKey(bgm)
+			if key == "title:\x00" {
+				continue
+			}
+
+			track, ok := tracks[key]
+			if !ok {
+				track = &BGMTrack{Path: bgm.Path, Locations: []string{}}
+				tracks[key] = track
+			}
+
+			if track.Title == "" {
+				track.Title = bgm.Title
+			}
+			if track.Label == "" {
+				track.Label = bgm.Label
+			}
+			if !slices.Contains(track.Locations, location.Title) {
+				track.Locations = append(track.Locations, location.Title)
+			}
+		}
+	}
+
+	catalog := &BGMCatalog{
+		Game: gameCode,
+		BGMs: make([]*BGMTrack, 0, len(tracks)),
+	}
+	for _, track := range tracks {
+		sort.Strings(track.Locations)
+		catalog.BGMs = append(catalog.BGMs, track)
+	}
+
+	sort.Slice(catalog.BGMs, func(i, j int) bool {
+		if catalog.BGMs[i].Title != catalog.BGMs[j].Title {
+			return catalog.BGMs[i].Title < catalog.BGMs[j].Title
+		}
+		return catalog.BGMs[i].Path < catalog.BGMs[j].Path
+	})
+
+	return catalog
+}
+
+func GetBGMCatalog(gameCode string, wikiConfig setup.WikiConfig) (catalog *BGMCatalog, err error) {
+	locations, _, err := CachedLocations(gameCode, wikiConfig)
+	if err != nil {
+		return catalog, err
+	}
+
+	return BuildBGMCatalog(gameCode, locations), nil
+}
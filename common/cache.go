@@ -0,0 +1,220 @@
+package common
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ynoproject/wikiwrapper/setup"
+)
+
+const defaultCacheTtl = time.Hour
+
+type cacheEntry struct {
+	data      interface{}
+	fetchedAt time.Time
+}
+
+type cacheSnapshot[T any] struct {
+	FetchedAt time.Time `json:"fetchedAt"`
+	Data      T         `json:"data"`
+}
+
+// cacheCall is a refresh in progress. Requests needing the same key while
+// it runs wait for its result instead of fetching the data again.
+type cacheCall struct {
+	done      chan struct{}
+	data      interface{}
+	fetchedAt time.Time
+	err       error
+}
+
+var (
+	cacheMutex   sync.Mutex
+	cacheEntries = map[string]*cacheEntry{}
+	cacheCalls   = map[string]*cacheCall{}
+)
+
+// cached returns the data stored under key while it is fresher than the
+// configured TTL, calling fetch to replace it otherwise. Only one fetch per
+// key runs at a time; concurrent callers share its result. When fetch fails,
+// stale data, from memory or from the snapshot saved in the cache directory,
+// is returned instead of the error. The returned data is shared and must not
+// be modified.
+func cached[T any](wikiConfig setup.WikiConfig, key string, fetch func() (T, error)) (T, time.Time, error) {
+	ttl := wikiConfig.Cache.Ttl
+	if ttl == 0 {
+		ttl = defaultCacheTtl
+	}
+
+	cacheMutex.Lock()
+	entry, ok := cacheEntries[key]
+	cacheMutex.Unlock()
+
+	if !ok {
+		if snapshot, err := readSnapshot[T](wikiConfig, key); err == nil {
+			entry = &cacheEntry{data: snapshot.Data, fetchedAt: snapshot.FetchedAt}
+			storeEntry(key, entry)
+		}
+	}
+
+	if entry != nil && time.Since(entry.fetchedAt) < ttl {
+		return entry.data.(T), entry.fetchedAt, nil
+	}
+
+	cacheMutex.Lock()
+	call, inFlight := cacheCalls[key]
+	if !inFlight {
+		call = &cacheCall{done: make(chan struct{})}
+		cacheCalls[key] = call
+	}
+	cacheMutex.Unlock()
+
+	if inFlight {
+		<-call.done
+	} else {
+		call.data, call.fetchedAt, call.err = refresh(wikiConfig, key, entry, fetch)
+
+		cacheMutex.Lock()
+		delete(cacheCalls, key)
+		cacheMutex.Unlock()
+		close(call.done)
+	}
+
+	if call.err != nil {
+		var zero T
+		return zero, time.Time{}, call.err
+	}
+	return call.data.(T), call.fetchedAt, nil
+}
+
+// refresh fetches the data of a key and stores it, falling back to the stale
+// entry, if any, when the fetch fails.
+func refresh[T any](wikiConfig setup.WikiConfig, key string, stale *cacheEntry, fetch func() (T, error)) (interface{}, time.Time, error) {
+	data, err := fetch()
+	if err != nil {
+		if stale != nil {
+			log.Printf("serving stale %s from cache: %v", key, err)
+			return stale.data, stale.fetchedAt, nil
+		}
+		return nil, time.Time{}, err
+	}
+
+	entry := &cacheEntry{data: data, fetchedAt: time.Now()}
+	storeEntry(key, entry)
+	if err := writeSnapshot(wikiConfig, key, cacheSnapshot[T]{FetchedAt: entry.fetchedAt, Data: data}); err != nil {
+		log.Printf("could not save %s to cache: %v", key, err)
+	}
+
+	return data, entry.fetchedAt, nil
+}
+
+func storeEntry(key string, entry *cacheEntry) {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+	cacheEntries[key] = entry
+}
+
+func snapshotPath(wikiConfig setup.WikiConfig, key string) string {
+	return filepath.Join(wikiConfig.Cache.Dir, key+".json")
+}
+
+func readSnapshot[T any](wikiConfig setup.WikiConfig, key string) (snapshot cacheSnapshot[T], err error) {
+	if wikiConfig.Cache.Dir == "" {
+		return snapshot, os.ErrNotExist
+	}
+
+	data, err := os.ReadFile(snapshotPath(wikiConfig, key))
+	if err != nil {
+		return snapshot, err
+	}
+
+	err = json.Unmarshal(data, &snapshot)
+	return snapshot, err
+}
+
+func writeSnapshot[T any](wikiConfig setup.WikiConfig, key string, snapshot cacheSnapshot[T]) error {
+	if wikiConfig.Cache.Dir == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(wikiConfig.Cache.Dir, 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated
+	// snapshot behind.
+	path := snapshotPath(wikiConfig, key)
+	if err := os.WriteFile(path+".tmp", data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// locationSet is every location of a game with the warnings raised while
+// parsing them.
+type locationSet struct {
+	Locations []*Location `json:"locations"`
+	Warnings  []*Warning  `json:"warnings,omitempty"`
+}
+
+type connectionSet struct {
+	Connections []*Connection `json:"connections"`
+	Warnings    []*Warning    `json:"warnings,omitempty"`
+}
+
+// CachedLocations returns every location of a game, fetched at most once per
+// cache TTL, with the warnings about the pages that could not be fully parsed.
+func CachedLocations(gameCode string, wikiConfig setup.WikiConfig) ([]*Location, []*Warning, error) {
+	set, _, err := cachedLocations(gameCode, wikiConfig)
+	return set.Locations, set.Warnings, err
+}
+
+func cachedLocations(gameCode string, wikiConfig setup.WikiConfig) (locationSet, time.Time, error) {
+	if _, ok := wikiConfig.Games[gameCode]; !ok {
+		return locationSet{}, time.Time{}, NewError(ErrGameNotSupported, "game not supported")
+	}
+
+	return cached(wikiConfig, "locations-"+gameCode, func() (locationSet, error) {
+		locations, warnings, err := GetAllLocations(gameCode, wikiConfig)
+		return locationSet{Locations: locations, Warnings: warnings}, err
+	})
+}
+
+// cachedLocationProtags maps the title of each cached location of a game to
+// the protagonists it belongs to.
+func cachedLocationProtags(gameCode string, wikiConfig setup.WikiConfig) (map[string][]string, error) {
+	locations, _, err := CachedLocations(gameCode, wikiConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	locationProtags := make(map[string][]string, len(locations))
+	for _, location := range locations {
+		locationProtags[location.Title] = location.Protags
+	}
+	return locationProtags, nil
+}
+
+// CachedConnections returns every connection of a game, fetched at most once
+// per cache TTL, with the warnings about the records that could not be fully
+// parsed.
+func CachedConnections(gameCode string, wikiConfig setup.WikiConfig) ([]*Connection, []*Warning, error) {
+	if _, ok := wikiConfig.Games[gameCode]; !ok {
+		return nil, nil, NewError(ErrGameNotSupported, "game not supported")
+	}
+
+	set, _, err := cached(wikiConfig, "connections-"+gameCode, func() (connectionSet, error) {
+		connections, warnings, err := GetAllConnections(gameCode, wikiConfig)
+		return connectionSet{Connections: connections, Warnings: warnings}, err
+	})
+	return set.Connections, set.Warnings, err
+}
@@ -0,0 +1,156 @@
+package common
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/ynoproject/wikiwrapper/setup"
+)
+
+type ChangelogVersion struct {
+	Version string   `json:"version"`
+	Added   []string `json:"added"`
+	Updated []string `json:"updated"`
+	Removed []string `json:"removed"`
+}
+
+type Changelog struct {
+	Game     string              `json:"game"`
+	From     string              `json:"from,omitempty"`
+	To       string              `json:"to,omitempty"`
+	Versions []*ChangelogVersion `json:"versions"`
+	Warnings []*Warning          `json:"warnings,omitempty"`
+}
+
+type changelogEntry struct {
+	version Version
+	change  string
+	title   string
+}
+
+const (
+	changeAdded   = "added"
+	changeUpdated = "updated"
+	changeRemoved = "removed"
+)
+
+// BuildChangelog lists the locations added, updated and removed in each
+// version from from to to, both included, oldest first. A nil bound leaves
+// that side of the range open. Versions that cannot be parsed are reported
+// as warnings.
+func BuildChangelog(gameCode string, locations []*Location, from *Version, to *Version) *Changelog {
+	changelog := &Changelog{
+		Game:     gameCode,
+		Versions: []*ChangelogVersion{},
+	}
+
+	var entries []changelogEntry
+	addEntry := func(location *Location, field string, change string, value string) {
+		if value == "" {
+			return
+		}
+
+		version, err := ParseVersion(value)
+		if err != nil {
+			changelog.Warnings = append(changelog.Warnings, &Warning{Page: location.Title, Field: field, Message: err.Error()})
+			return
+		}
+
+		if (from != nil && version.Compare(*from) < 0) || (to != nil && version.Compare(*to) > 0) {
+			return
+		}
+
+		entries = append(entries, changelogEntry{version: version, change: change, title: location.Title})
+	}
+
+	for _, location := range locations {
+		addEntry(location, "versionAdded", changeAdded, location.VersionAdded)
+		for _, versionUpdated := range location.VersionsUpdated {
+			addEntry(location, "versionsUpdated", changeUpdated, versionUpdated)
+		}
+		addEntry(location, "versionRemoved", changeRemoved, location.VersionRemoved)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if c := entries[i].version.Compare(entries[j].version); c != 0 {
+			return c < 0
+		}
+		return entries[i].title < entries[j].title
+	})
+
+	var current *ChangelogVersion
+	var currentVersion Version
+	for _, entry := range entries {
+		if current == nil || entry.version.Compare(currentVersion) != 0 {
+			current = &ChangelogVersion{
+				Version: entry.version.String(),
+				Added:   []string{},
+				Updated: []string{},
+				Removed: []string{},
+			}
+			currentVersion = entry.version
+			changelog.Versions = append(changelog.Versions, current)
+		}
+
+		switch entry.change {
+		case changeAdded:
+			current.Added = appendUnique(current.Added, entry.title)
+		case changeUpdated:
+			current.Updated = appendUnique(current.Updated, entry.title)
+		case changeRemoved:
+			current.Removed = appendUnique(current.Removed, entry.title)
+		}
+	}
+
+	return changelog
+}
+
+// appendUnique appends a title unless it is already the last one; titles
+// arrive sorted, so repeats are adjacent.
+func appendUnique(titles []string, title string) []string {
+	if len(titles) > 0 && titles[len(titles)-1] == title {
+		return titles
+	}
+	return append(titles, title)
+}
+
+// parseVersionBound reads an optional version parameter, returning nil when
+// it is empty.
+func parseVersionBound(value string) (*Version, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	version, err := ParseVersion(value)
+	if err != nil {
+		return nil, &Error{Code: ErrInvalidParameter, Message: err.Error(), Err: err}
+	}
+	return &version, nil
+}
+
+func GetChangelog(gameCode string, from string, to string, wikiConfig setup.WikiConfig) (changelog *Changelog, err error) {
+	fromVersion, err := parseVersionBound(from)
+	if err != nil {
+		return changelog, err
+	}
+
+	toVersion, err := parseVersionBound(to)
+	if err != nil {
+		return changelog, err
+	}
+
+	if fromVersion != nil && toVersion != nil && fromVersion.Compare(*toVersion) > 0 {
+		return changelog, NewError(ErrInvalidParameter, "from must not be newer than to")
+	}
+
+	locations, warnings, err := CachedLocations(gameCode, wikiConfig)
+	if err != nil {
+		return changelog, err
+	}
+
+	changelog = BuildChangelog(gameCode, locations, fromVersion, toVersion)
+	changelog.Warnings = append(slices.Clone(warnings), changelog.Warnings...)
+	changelog.From = from
+	changelog.To = to
+	return changelog, nil
+}
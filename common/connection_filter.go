@@ -0,0 +1,225 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ynoproject/wikiwrapper/setup"
+)
+
+// ConnectionFilter selects connections by their properties. Empty and nil
+// fields do not filter. Values are compared case-insensitively.
+type ConnectionFilter struct {
+	Attribute      string
+	Season         string
+	Effect         string
+	Origin         string
+	Destination    string
+	IncludeRemoved *bool
+	AsOfVersion    string
+}
+
+// IsEmpty reports whether the filter keeps every connection.
+func (f ConnectionFilter) IsEmpty() bool {
+	return f == ConnectionFilter{}
+}
+
+func (f ConnectionFilter) Match(connection *Connection) bool {
+	if f.Attribute != "" && !containsFold(connection.Attributes, f.Attribute) {
+		return false
+	}
+
+	if f.Season != "" && !matchSeason(connection.SeasonAvailable, f.Season) {
+		return false
+	}
+
+	if f.Effect != "" && !containsFold(connection.EffectsNeeded, f.Effect) {
+		return false
+	}
+
+	if f.Origin != "" && !strings.EqualFold(connection.Origin, f.Origin) {
+		return false
+	}
+
+	if f.Destination != "" && !strings.EqualFold(connection.Destination, f.Destination) {
+		return false
+	}
+
+	if f.IncludeRemoved != nil && !*f.IncludeRemoved && connection.IsRemoved {
+		return false
+	}
+
+	return true
+}
+
+// matchSeason reports whether a connection available in the given seasons is
+// available in any of the requested ones, so "summer" matches "Spring and
+// Summer". Values naming no season are compared as they are.
+func matchSeason(available string, requested string) bool {
+	requestedSeasons := parseSeasons(requested)
+	if len(requestedSeasons) == 0 {
+		return strings.EqualFold(strings.TrimSpace(available), strings.TrimSpace(requested))
+	}
+
+	for _, season := range parseSeasons(available) {
+		if slices.Contains(requestedSeasons, season) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	return slices.ContainsFunc(values, func(v string) bool {
+		return strings.EqualFold(v, value)
+	})
+}
+
+// FilterConnections returns the connections matching the filter.
+func FilterConnections(connections []*Connection, filter ConnectionFilter) []*Connection {
+	filtered := []*Connection{}
+	for _, connection := range connections {
+		if filter.Match(connection) {
+			filtered = append(filtered, connection)
+		}
+	}
+	return filtered
+}
+
+// GetFilteredConnections filters the whole connection set of a game. Results
+// are not paged. For games with several protagonists, only the connections
+// the requested protagonist can take are kept unless it is empty or
+// AllProtags.
+func GetFilteredConnections(gameParams GameParams, filter ConnectionFilter, wikiConfig setup.WikiConfig) (connections *Connections, err error) {
+	game, ok := wikiConfig.Games[gameParams.GameCode]
+	if !ok {
+		return connections, NewError(ErrGameNotSupported, "game not supported")
+	}
+
+	hasMultipleProtags := len(game.Protagonists) > 0
+	allProtags := gameParams.Protag == "" || gameParams.Protag == AllProtags
+
+	if !hasMultipleProtags && !allProtags {
+		return connections, NewError(ErrProtagUnknown, "game has only one protagonist")
+	}
+
+	var asOfVersion Version
+	if filter.AsOfVersion != "" {
+		asOfVersion, err = ParseVersion(filter.AsOfVersion)
+		if err != nil {
+			return connections, &Error{Code: ErrInvalidParameter, Message: err.Error(), Err: err}
+		}
+	}
+
+	connections = &Connections{
+		Game: gameParams.GameCode,
+	}
+
+	if hasMultipleProtags && allProtags {
+		connections.Protags = acceptedProtags(game)
+	} else if hasMultipleProtags {
+		if _, ok := game.Protagonists[gameParams.Protag]; !ok {
+			return connections, &Error{
+				Code:     ErrProtagUnknown,
+				Message:  "protagonist does not exist or is misspelled",
+				Accepted: acceptedProtags(game),
+			}
+		}
+		connections.Protags = []string{gameParams.Protag}
+	}
+
+	allConnections, warnings, err := CachedConnections(gameParams.GameCode, wikiConfig)
+	if err != nil {
+		return connections, err
+	}
+	connections.Warnings = warnings
+
+	if hasMultipleProtags && !allProtags {
+		protagConnections := []*Connection{}
+		for _, connection := range allConnections {
+			if slices.Contains(connection.Protags, gameParams.Protag) {
+				protagConnections = append(protagConnections, connection)
+			}
+		}
+		allConnections = protagConnections
+	}
+
+	if filter.AsOfVersion != "" {
+		locations, _, err := CachedLocations(gameParams.GameCode, wikiConfig)
+		if err != nil {
+			return connections, err
+		}
+		var asOfWarnings []*Warning
+		allConnections, asOfWarnings = ConnectionsAsOf(allConnections, locations, asOfVersion)
+		connections.Warnings = append(slices.Clone(connections.Warnings), asOfWarnings...)
+	}
+
+	connections.Connections = FilterConnections(allConnections, filter)
+	return connections, nil
+}
+
+// ConnectionsAsOf returns the connections that existed in a version: those
+// whose origin and destination were both present in it. The wiki does not
+// record when a removed connection was removed, so one is kept only while the
+// version is older than the last update of either of its locations, the
+// update that must have removed it. Without any update recorded, it is kept.
+// Since that is a guess, a warning is returned for each removed connection
+// decided this way.
+func ConnectionsAsOf(connections []*Connection, locations []*Location, version Version) (existing []*Connection, warnings []*Warning) {
+	locationsByTitle := map[string]*Location{}
+	for _, location := range locations {
+		locationsByTitle[location.Title] = location
+	}
+
+	existing = []*Connection{}
+	for _, connection := range connections {
+		origin, hasOrigin := locationsByTitle[connection.Origin]
+		destination, hasDestination := locationsByTitle[connection.Destination]
+		if !hasOrigin || !hasDestination || !origin.PresentIn(version) || !destination.PresentIn(version) {
+			continue
+		}
+
+		if connection.IsRemoved {
+			lastUpdate, ok := lastUpdated(origin, destination)
+			switch {
+			case !ok:
+				warnings = append(warnings, removedConnectionWarning(connection, "assumed present since no update of its locations is recorded"))
+			case version.Compare(lastUpdate) >= 0:
+				warnings = append(warnings, removedConnectionWarning(connection, "assumed removed by the update in "+lastUpdate.String()))
+				continue
+			default:
+				warnings = append(warnings, removedConnectionWarning(connection, "assumed present until the update in "+lastUpdate.String()))
+			}
+		}
+
+		existing = append(existing, connection)
+	}
+	return existing, warnings
+}
+
+func removedConnectionWarning(connection *Connection, guess string) *Warning {
+	return &Warning{
+		Page:    connection.Origin,
+		Field:   "isRemoved",
+		Message: fmt.Sprintf("removed connection to %s %s", connection.Destination, guess),
+	}
+}
+
+// lastUpdated returns the most recent version in which any of the locations
+// was updated or removed.
+func lastUpdated(locations ...*Location) (last Version, ok bool) {
+	for _, location := range locations {
+		versions := append([]string{location.VersionRemoved}, location.VersionsUpdated...)
+		for _, value := range versions {
+			version, err := ParseVersion(value)
+			if err != nil {
+				continue
+			}
+			if !ok || version.Compare(last) > 0 {
+				last, ok = version, true
+			}
+		}
+	}
+	return last, ok
+}
@@ -0,0 +1,122 @@
+package common
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ynoproject/wikiwrapper/setup"
+)
+
+type Season string
+
+const (
+	SeasonSpring Season = "spring"
+	SeasonSummer Season = "summer"
+	SeasonFall   Season = "fall"
+	SeasonWinter Season = "winter"
+)
+
+var seasonNames = map[string]Season{
+	"spring": SeasonSpring,
+	"summer": SeasonSummer,
+	"fall":   SeasonFall,
+	"autumn": SeasonFall,
+	"winter": SeasonWinter,
+}
+
+// parseSeasons reads the seasons listed in a free-form season value such as
+// "Spring and Summer". Words that are not seasons are ignored.
+func parseSeasons(value string) []Season {
+	var seasons []Season
+	words := strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return r == ',' || r == '/' || r == '&' || r == ' '
+	})
+	for _, word := range words {
+		if season, ok := seasonNames[word]; ok && !slices.Contains(seasons, season) {
+			seasons = append(seasons, season)
+		}
+	}
+	return seasons
+}
+
+// parseChance reads a chance percentage such as "25", "25%" or "0,5 %".
+func parseChance(value string) (*float64, error) {
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "%"))
+	if value == "" {
+		return nil, nil
+	}
+
+	chance, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+	if err != nil {
+		return nil, fmt.Errorf("chance percentage %q is not a number", value)
+	}
+
+	if chance < 0 || chance > 100 {
+		return nil, fmt.Errorf("chance percentage %q is not between 0 and 100", value)
+	}
+
+	return &chance, nil
+}
+
+// NewTypedConnection normalizes a connection. Attribute values without a
+// mapping are kept in OtherAttributes. A chance that cannot be parsed is left
+// out and reported in the returned error.
+func NewTypedConnection(connection *Connection, wikiConfig setup.WikiConfig) (*TypedConnection, error) {
+	typed := &TypedConnection{
+		Game:              connection.Game,
+		Origin:            connection.Origin,
+		Destination:       connection.Destination,
+		Attributes:        []string{},
+		UnlockConditions:  connection.UnlockConditions,
+		EffectsNeeded:     connection.EffectsNeeded,
+		Seasons:           parseSeasons(connection.SeasonAvailable),
+		ChanceDescription: connection.ChanceDescription,
+		IsRemoved:         connection.IsRemoved,
+		Protags:           connection.Protags,
+	}
+
+	for _, value := range connection.Attributes {
+		attribute, ok := wikiConfig.ConnectionAttribute(value)
+		if !ok {
+			typed.OtherAttributes = append(typed.OtherAttributes, value)
+			continue
+		}
+
+		if !slices.Contains(typed.Attributes, attribute) {
+			typed.Attributes = append(typed.Attributes, attribute)
+		}
+	}
+
+	chance, err := parseChance(connection.ChancePercentage)
+	typed.Chance = chance
+	return typed, err
+}
+
+// TypeConnections converts a connections response to version 2. Chances that
+// cannot be parsed are reported as warnings.
+func TypeConnections(connections *Connections, wikiConfig setup.WikiConfig) *TypedConnections {
+	typed := &TypedConnections{
+		Version:     2,
+		Connections: []*TypedConnection{},
+		Game:        connections.Game,
+		Protags:     connections.Protags,
+		ContinueKey: connections.ContinueKey,
+		Warnings:    slices.Clone(connections.Warnings),
+	}
+
+	for _, connection := range connections.Connections {
+		typedConnection, err := NewTypedConnection(connection, wikiConfig)
+		if err != nil {
+			typed.Warnings = append(typed.Warnings, &Warning{
+				Page:    connection.Origin + " -> " + connection.Destination,
+				Field:   "chance",
+				Message: err.Error(),
+			})
+		}
+		typed.Connections = append(typed.Connections, typedConnection)
+	}
+
+	return typed
+}
@@ -1,10 +1,11 @@
 package common
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -16,18 +17,116 @@ import (
 
 type GameParams struct {
 	GameCode, Protag, ContinueKey string
+	Lenient                       bool
+}
+
+// AllProtags is the protagonist value requesting the data of every
+// protagonist of a game at once.
+const AllProtags = "all"
+
+// parseReport collects the problems found while parsing the records of a
+// response. In lenient mode a field that fails to parse is left empty and a
+// record that cannot be read at all is skipped, both reported as warnings;
+// otherwise the first problem aborts the response.
+type parseReport struct {
+	lenient  bool
+	warnings []*Warning
+}
+
+func (r *parseReport) fail(page string, field string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	log.Print("SERVER", page, field, err.Error())
+	if !r.lenient {
+		return err
+	}
+
+	r.warnings = append(r.warnings, &Warning{Page: page, Field: field, Message: err.Error()})
+	return nil
+}
+
+// decoded reports the field errors of a decoded record. Any other error means
+// the record could not be read at all and is returned as is.
+func (r *parseReport) decoded(page string, err error) error {
+	var decodeErrs DecodeErrors
+	if !errors.As(err, &decodeErrs) {
+		return err
+	}
+
+	for _, decodeErr := range decodeErrs {
+		if err := r.fail(page, decodeErr.Field, decodeErr.Err); err != nil {
+			return decodeErr
+		}
+	}
+	return nil
+}
+
+func (r *parseReport) skip(page string, err error) error {
+	if err == nil || !r.lenient {
+		return err
+	}
+
+	log.Print("SERVER", page, "skipped", err.Error())
+	r.warnings = append(r.warnings, &Warning{Page: page, Message: err.Error(), Skipped: true})
+	return nil
+}
+
+func locationPrintouts(game setup.Game) []string {
+	return []string{
+		game.Property(setup.PropertyLocationImage),
+		game.Property(setup.PropertyLocationBackgroundColor),
+		game.Property(setup.PropertyLocationFontColor),
+		game.Property(setup.PropertyLocationPrimaryAuthor),
+		game.Property(setup.PropertyLocationContributingAuthor),
+		game.Property(setup.PropertyLocationOriginalName),
+		game.Property(setup.PropertyLocationBGM),
+		game.Property(setup.PropertyLocationMapIds),
+		game.Property(setup.PropertyLocationMap),
+		game.Property(setup.PropertyLocationVersionAdded),
+		game.Property(setup.PropertyLocationVersionsUpdated),
+		game.Property(setup.PropertyLocationVersionRemoved),
+		game.Property(setup.PropertyLocationVersionGaps),
+	}
+}
+
+func connectionPrintouts(game setup.Game) []string {
+	return []string{
+		game.Property(setup.PropertyConnectionOrigin),
+		game.Property(setup.PropertyConnectionDestination),
+		game.Property(setup.PropertyConnectionAttribute),
+		game.Property(setup.PropertyConnectionUnlockConditions),
+		game.Property(setup.PropertyConnectionEffectsNeeded),
+		game.Property(setup.PropertyConnectionSeasonAvailable),
+		game.Property(setup.PropertyConnectionChancePercentage),
+		game.Property(setup.PropertyConnectionChanceDescription),
+		game.Property(setup.PropertyConnectionIsRemoved),
+	}
 }
 
 func createClient() (client *mwclient.Client, err error) {
 	client, err = mwclient.New("https://yume.wiki/api.php", "yumeWikiAPIBot")
+	if err != nil {
+		return client, upstreamError(err)
+	}
 	client.SetHTTPTimeout(60000000000)
 	return client, err
 }
 
+// acceptedProtags returns the protagonist codes of a game in a stable order.
+func acceptedProtags(game setup.Game) []string {
+	protags := make([]string, 0, len(game.Protagonists))
+	for protag := range game.Protagonists {
+		protags = append(protags, protag)
+	}
+	sort.Strings(protags)
+	return protags
+}
+
 func fetchAllResultsFromSmwQuery(smwQuery *SmwQuery) (results []*jason.Object, err error) {
 	for smwQuery.Next() {
-		result := smwQuery.Resp()
-		fetchedResults, err := result.GetObjectArray("query", "results")
+		fetchedResults, err := smwQuery.Results()
 
 		if err != nil {
 			return results, err
@@ -43,33 +142,49 @@ func fetchAllResultsFromSmwQuery(smwQuery *SmwQuery) (results []*jason.Object, e
 	return results, err
 }
 
+// fetchSmwQueryPage fetches a single page of results, starting at the offset
+// given by a continue key.
+func fetchSmwQueryPage(client *mwclient.Client, query *AskArgsQuery, continueKey string) (*SmwQuery, error) {
+	if continueKey != "" {
+		offset, err := strconv.Atoi(continueKey)
+		if err != nil {
+			return nil, NewError(ErrInvalidParameter, "continueKey must be a number")
+		}
+		query.Offset(offset)
+	}
+
+	results := NewSmwQuery(client, query)
+	results.Next()
+	return results, results.Err()
+}
+
 func GetLocations(gameParams GameParams, wikiConfig setup.WikiConfig) (locations *Locations, err error) {
 	game, ok := wikiConfig.Games[gameParams.GameCode]
 	if !ok {
-		return locations, errors.New("game not supported")
+		return locations, NewError(ErrGameNotSupported, "game not supported")
 	}
 
 	protagCategories := game.Protagonists
 	hasMultipleProtags := len(protagCategories) > 0
 
-	if !hasMultipleProtags && gameParams.Protag != "" {
-		return locations, errors.New("game has only one protagonist")
+	allProtags := gameParams.Protag == AllProtags
+
+	if !hasMultipleProtags && gameParams.Protag != "" && !allProtags {
+		return locations, NewError(ErrProtagUnknown, "game has only one protagonist")
 	}
 
 	if hasMultipleProtags && len(gameParams.Protag) == 0 {
-		acceptedProtags := make([]string, 0, len(protagCategories))
-		for protag := range protagCategories {
-			acceptedProtags = append(acceptedProtags, protag)
-		}
 		locations = &Locations{
 			Game:    gameParams.GameCode,
-			Protags: acceptedProtags,
+			Protags: acceptedProtags(game),
 		}
 		return locations, nil
 	}
 
 	var responseProtags []string
-	if hasMultipleProtags && gameParams.Protag != "" {
+	if hasMultipleProtags && allProtags {
+		responseProtags = acceptedProtags(game)
+	} else if hasMultipleProtags && gameParams.Protag != "" {
 		responseProtags = []string{gameParams.Protag}
 	} else if !hasMultipleProtags {
 		responseProtags = []string{}
@@ -81,15 +196,15 @@ func GetLocations(gameParams GameParams, wikiConfig setup.WikiConfig) (locations
 	}
 
 	protagCategory := ""
-	if hasMultipleProtags && gameParams.Protag != "" {
+	if hasMultipleProtags && gameParams.Protag != "" && !allProtags {
 		protagCategory, ok = protagCategories[gameParams.Protag]
 
 		if !ok {
-			acceptedProtags := make([]string, 0, len(protagCategories))
-			for protag := range protagCategories {
-				acceptedProtags = append(acceptedProtags, protag)
+			return locations, &Error{
+				Code:     ErrProtagUnknown,
+				Message:  "protagonist does not exist or is misspelled",
+				Accepted: acceptedProtags(game),
 			}
-			return locations, fmt.Errorf("protagonist does not exist or is misspelled (accepted values are: %v)", acceptedProtags)
 		}
 	}
 
@@ -98,61 +213,68 @@ func GetLocations(gameParams GameParams, wikiConfig setup.WikiConfig) (locations
 		return locations, err
 	}
 
-	condition := fmt.Sprintf("Category:%s Locations", game.Name)
+	askQuery := NewAskArgsQuery().
+		ApiVersion(wikiConfig.SmwApiVersion).
+		Category(game.Name + " Locations").
+		Printout(locationPrintouts(game)...).
+		Limit(250)
 	if protagCategory != "" {
-		condition += "|" + protagCategory
-	}
-	printouts := []string{"Has location image", "Header background color", "Header font color", "Has primary author", "Has contributing author", "Japanese name", "Has BGM", "Map IDs", "Has location map", "Version added", "Versions updated", "Version removed", "Version gaps"}
-
-	parameters := params.Values{
-		"action":      "askargs",
-		"conditions":  condition,
-		"printouts":   strings.Join(printouts, "|"),
-		"parameters":  "limit=250",
-		"format":      "json",
-		"api_version": "3",
+		askQuery.Condition(protagCategory)
 	}
 
-	if gameParams.ContinueKey != "" {
-		offset := fmt.Sprintf("|offset=%s", gameParams.ContinueKey)
-		currentParams := parameters.Get("parameters")
-		parameters.Set("parameters", currentParams+offset)
-	}
-
-	query, err := client.Get(parameters)
+	query, err := fetchSmwQueryPage(client, askQuery, gameParams.ContinueKey)
 	if err != nil {
 		return locations, err
 	}
 
-	continueKey, err := query.GetNumber("query-continue-offset")
+	continueKey, err := query.Resp().GetNumber("query-continue-offset")
 	if err == nil {
 		locations.ContinueKey = string(continueKey)
 	}
 
-	locationsToProcess, err := query.GetObjectArray("query", "results")
+	locationsToProcess, err := query.Results()
 	if err != nil {
 		return locations, err
 	}
 
+	report := &parseReport{lenient: gameParams.Lenient}
 	for _, locationToProcess := range locationsToProcess {
-		for _, value := range locationToProcess.Map() {
+		for page, value := range locationToProcess.Map() {
 			value, err := value.Object()
 			if err != nil {
-				return locations, err
+				if err := report.skip(page, err); err != nil {
+					return locations, err
+				}
+				continue
 			}
 
-			location, err := processLocation(game.Name, value)
+			location, err := processLocation(game, value, report)
 			if err != nil {
-				return locations, err
+				if err := report.skip(page, err); err != nil {
+					return locations, err
+				}
+				continue
 			}
 
-			if gameParams.Protag != "" {
+			if gameParams.Protag != "" && !allProtags {
 				location.Protags = []string{gameParams.Protag}
 			}
 
 			locations.Locations = append(locations.Locations, location)
 		}
 	}
+	locations.Warnings = report.warnings
+
+	if hasMultipleProtags && allProtags {
+		locationProtags, err := cachedLocationProtags(gameParams.GameCode, wikiConfig)
+		if err != nil {
+			return locations, err
+		}
+
+		for _, location := range locations.Locations {
+			location.Protags = locationProtags[location.Title]
+		}
+	}
 
 	return locations, err
 }
@@ -160,38 +282,33 @@ func GetLocations(gameParams GameParams, wikiConfig setup.WikiConfig) (locations
 func GetConnections(gameParams GameParams, wikiConfig setup.WikiConfig) (connections *Connections, err error) {
 	game, ok := wikiConfig.Games[gameParams.GameCode]
 	if !ok {
-		return connections, errors.New("game not supported")
+		return connections, NewError(ErrGameNotSupported, "game not supported")
 	}
 
 	protagCategories := game.Protagonists
 	hasMultipleProtags := len(protagCategories) > 0
 
-	if !hasMultipleProtags && gameParams.Protag != "" {
-		return connections, errors.New("game has only one protagonist")
-	}
+	unfiltered := gameParams.Protag == "" || gameParams.Protag == AllProtags
 
-	if hasMultipleProtags && len(gameParams.Protag) == 0 {
-		acceptedProtags := make([]string, 0, len(protagCategories))
-		for protag := range protagCategories {
-			acceptedProtags = append(acceptedProtags, protag)
-		}
-		return connections, fmt.Errorf("game has multiple protagonists, please specify one (accepted values are: %v)", acceptedProtags)
+	if !hasMultipleProtags && !unfiltered {
+		return connections, NewError(ErrProtagUnknown, "game has only one protagonist")
 	}
 
 	connections = &Connections{
 		Game: gameParams.GameCode,
 	}
 
-	protagCategory := ""
-	if hasMultipleProtags && gameParams.Protag != "" {
-		protagCategory, ok = protagCategories[gameParams.Protag]
+	if hasMultipleProtags && unfiltered {
+		connections.Protags = acceptedProtags(game)
+	} else if hasMultipleProtags {
+		connections.Protags = []string{gameParams.Protag}
 
-		if !ok {
-			acceptedProtags := make([]string, 0, len(protagCategories))
-			for protag := range protagCategories {
-				acceptedProtags = append(acceptedProtags, protag)
+		if _, ok := protagCategories[gameParams.Protag]; !ok {
+			return connections, &Error{
+				Code:     ErrProtagUnknown,
+				Message:  "protagonist does not exist or is misspelled",
+				Accepted: acceptedProtags(game),
 			}
-			return connections, fmt.Errorf("protagonist does not exist or is misspelled (accepted values are: %v)", acceptedProtags)
 		}
 	}
 
@@ -200,85 +317,273 @@ func GetConnections(gameParams GameParams, wikiConfig setup.WikiConfig) (connect
 		return connections, err
 	}
 
-	conditions := []string{fmt.Sprintf("%s:+", game.Name), "Is subobject type::connection"}
-	if protagCategory != "" {
-		conditions = append(conditions, fmt.Sprintf("-Has subobject::<q>[[%s]]</q>", protagCategory))
-	}
-	printouts := []string{"Connection/Origin", "Connection/Location", "Connection/Attribute", "Connection/Unlock conditions", "Connection/Effects needed", "Connection/Season available", "Connection/Chance percentage", "Connection/Chance description", "Connection/Is removed"}
-
-	parameters := params.Values{
-		"action":      "askargs",
-		"conditions":  strings.Join(conditions, "|"),
-		"printouts":   strings.Join(printouts, "|"),
-		"parameters":  "limit=500",
-		"format":      "json",
-		"api_version": "3",
-	}
+	askQuery := NewAskArgsQuery().
+		ApiVersion(wikiConfig.SmwApiVersion).
+		Namespace(game.Name).
+		Property(game.Property(setup.PropertySubobjectType), "connection").
+		Printout(connectionPrintouts(game)...).
+		Limit(500)
 
-	if gameParams.ContinueKey != "" {
-		offset := fmt.Sprintf("|offset=%s", gameParams.ContinueKey)
-		currentParams := parameters.Get("parameters")
-		parameters.Set("parameters", currentParams+offset)
-	}
-
-	query, err := client.Get(parameters)
+	query, err := fetchSmwQueryPage(client, askQuery, gameParams.ContinueKey)
 	if err != nil {
 		return connections, err
 	}
 
-	continueKey, err := query.GetNumber("query-continue-offset")
+	continueKey, err := query.Resp().GetNumber("query-continue-offset")
 	if err == nil {
 		connections.ContinueKey = string(continueKey)
 	}
 
-	connectionsToProcess, err := query.GetObjectArray("query", "results")
+	connectionsToProcess, err := query.Results()
 	if err != nil {
 		return connections, err
 	}
 
+	var protagsByLocation map[string][]string
+	if hasMultipleProtags {
+		protagsByLocation, err = cachedLocationProtags(gameParams.GameCode, wikiConfig)
+		if err != nil {
+			return connections, err
+		}
+	}
+
+	report := &parseReport{lenient: gameParams.Lenient}
 	for _, connectionToProcess := range connectionsToProcess {
-		for _, value := range connectionToProcess.Map() {
+		for page, value := range connectionToProcess.Map() {
 			value, err := value.Object()
 			if err != nil {
-				return connections, err
+				if err := report.skip(page, err); err != nil {
+					return connections, err
+				}
+				continue
 			}
 
-			connection, err := processConnection(gameParams.GameCode, value)
+			connection, err := processConnection(gameParams.GameCode, game, value, report)
 			if err != nil {
-				return connections, err
+				if err := report.skip(page, err); err != nil {
+					return connections, err
+				}
+				continue
+			}
+
+			// Connections are filtered after paging, so a page can hold fewer
+			// than the limit while more remain.
+			if hasMultipleProtags {
+				connection.Protags = connectionProtags(connection, protagsByLocation)
+				if !unfiltered && !slices.Contains(connection.Protags, gameParams.Protag) {
+					continue
+				}
 			}
 
 			connections.Connections = append(connections.Connections, connection)
 		}
 	}
+	connections.Warnings = report.warnings
 
 	return connections, err
 }
 
-func GetAuthors(gameCode string, wikiConfig setup.WikiConfig) (authors []*Author, err error) {
+// GetAllLocations fetches every location of a game, following continuation
+// offsets until the wiki has no more results. Protagonists are not filtered;
+// for games with several of them, each location lists the ones it belongs to.
+// Pages are parsed leniently, so a malformed page is reported as a warning
+// instead of failing the whole set.
+func GetAllLocations(gameCode string, wikiConfig setup.WikiConfig) (locations []*Location, warnings []*Warning, err error) {
+	locations = []*Location{}
 	game, ok := wikiConfig.Games[gameCode]
 	if !ok {
-		return authors, errors.New("game not supported")
+		return locations, warnings, NewError(ErrGameNotSupported, "game not supported")
 	}
 
 	client, err := createClient()
 	if err != nil {
-		return authors, err
+		return locations, warnings, err
 	}
 
-	conditions := fmt.Sprintf("-Has subobject::%s:Authors", game.Name)
-	printouts := []string{"Author/Name", "Author/Original Name"}
-	queryParams := []string{"sort=Author/Name", "order=asc", "limit=500"}
+	askQuery := NewAskArgsQuery().
+		ApiVersion(wikiConfig.SmwApiVersion).
+		Category(game.Name + " Locations").
+		Printout(locationPrintouts(game)...).
+		Limit(500)
 
-	parameters := params.Values{
-		"conditions":  conditions,
-		"printouts":   strings.Join(printouts, "|"),
-		"parameters":  strings.Join(queryParams, "|"),
-		"format":      "json",
-		"api_version": "3",
+	results := NewSmwQuery(client, askQuery)
+	locationsToProcess, err := fetchAllResultsFromSmwQuery(results)
+	if err != nil {
+		return locations, warnings, err
+	}
+
+	report := &parseReport{lenient: true}
+	for _, locationToProcess := range locationsToProcess {
+		for page, value := range locationToProcess.Map() {
+			value, err := value.Object()
+			if err != nil {
+				report.skip(page, err)
+				continue
+			}
+
+			location, err := processLocation(game, value, report)
+			if err != nil {
+				report.skip(page, err)
+				continue
+			}
+
+			locations = append(locations, location)
+		}
+	}
+
+	if len(game.Protagonists) > 0 {
+		err = assignProtags(client, game, wikiConfig, locations)
 	}
 
-	results := NewSmwQuery(client, parameters)
+	return locations, report.warnings, err
+}
+
+// assignProtags sets the protagonists of each location from the protagonist
+// categories its page belongs to.
+func assignProtags(client *mwclient.Client, game setup.Game, wikiConfig setup.WikiConfig, locations []*Location) error {
+	locationProtags, err := fetchLocationProtags(client, game, wikiConfig)
+	if err != nil {
+		return err
+	}
+
+	for _, location := range locations {
+		location.Protags = locationProtags[location.Title]
+	}
+
+	return nil
+}
+
+// fetchLocationProtags maps the title of each location of a game to the
+// protagonists whose category its page belongs to.
+func fetchLocationProtags(client *mwclient.Client, game setup.Game, wikiConfig setup.WikiConfig) (map[string][]string, error) {
+	locationProtags := map[string][]string{}
+	for _, protag := range acceptedProtags(game) {
+		askQuery := NewAskArgsQuery().
+			ApiVersion(wikiConfig.SmwApiVersion).
+			Category(game.Name + " Locations").
+			Condition(game.Protagonists[protag]).
+			Limit(500)
+
+		results, err := fetchAllResultsFromSmwQuery(NewSmwQuery(client, askQuery))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, result := range results {
+			for page := range result.Map() {
+				title := pageTitle(page)
+				locationProtags[title] = append(locationProtags[title], protag)
+			}
+		}
+	}
+
+	return locationProtags, nil
+}
+
+// connectionProtags infers the protagonists that can take a connection: the
+// ones its origin and destination have in common, or those of whichever end
+// is known when the other belongs to no protagonist.
+func connectionProtags(connection *Connection, locationProtags map[string][]string) []string {
+	originProtags := locationProtags[connection.Origin]
+	destinationProtags := locationProtags[connection.Destination]
+
+	if len(originProtags) == 0 {
+		return destinationProtags
+	}
+	if len(destinationProtags) == 0 {
+		return originProtags
+	}
+
+	protags := []string{}
+	for _, protag := range originProtags {
+		if slices.Contains(destinationProtags, protag) {
+			protags = append(protags, protag)
+		}
+	}
+	return protags
+}
+
+// GetAllConnections fetches every connection of a game across all pages,
+// regardless of protagonist. For games with several protagonists, each
+// connection lists the ones that can take it. Like GetAllLocations, records
+// are parsed leniently.
+func GetAllConnections(gameCode string, wikiConfig setup.WikiConfig) (connections []*Connection, warnings []*Warning, err error) {
+	connections = []*Connection{}
+	game, ok := wikiConfig.Games[gameCode]
+	if !ok {
+		return connections, warnings, NewError(ErrGameNotSupported, "game not supported")
+	}
+
+	client, err := createClient()
+	if err != nil {
+		return connections, warnings, err
+	}
+
+	askQuery := NewAskArgsQuery().
+		ApiVersion(wikiConfig.SmwApiVersion).
+		Namespace(game.Name).
+		Property(game.Property(setup.PropertySubobjectType), "connection").
+		Printout(connectionPrintouts(game)...).
+		Limit(500)
+
+	results := NewSmwQuery(client, askQuery)
+	connectionsToProcess, err := fetchAllResultsFromSmwQuery(results)
+	if err != nil {
+		return connections, warnings, err
+	}
+
+	report := &parseReport{lenient: true}
+	for _, connectionToProcess := range connectionsToProcess {
+		for page, value := range connectionToProcess.Map() {
+			value, err := value.Object()
+			if err != nil {
+				report.skip(page, err)
+				continue
+			}
+
+			connection, err := processConnection(gameCode, game, value, report)
+			if err != nil {
+				report.skip(page, err)
+				continue
+			}
+
+			connections = append(connections, connection)
+		}
+	}
+
+	if len(game.Protagonists) > 0 {
+		protagsByLocation, err := fetchLocationProtags(client, game, wikiConfig)
+		if err != nil {
+			return connections, report.warnings, err
+		}
+
+		for _, connection := range connections {
+			connection.Protags = connectionProtags(connection, protagsByLocation)
+		}
+	}
+
+	return connections, report.warnings, err
+}
+
+func GetAuthors(gameCode string, wikiConfig setup.WikiConfig) (authors []*Author, err error) {
+	game, ok := wikiConfig.Games[gameCode]
+	if !ok {
+		return authors, NewError(ErrGameNotSupported, "game not supported")
+	}
+
+	client, err := createClient()
+	if err != nil {
+		return authors, err
+	}
+
+	askQuery := NewAskArgsQuery().
+		ApiVersion(wikiConfig.SmwApiVersion).
+		Property("-Has subobject", game.Name+":Authors").
+		Printout(game.Property(setup.PropertyAuthorName), game.Property(setup.PropertyAuthorOriginalName)).
+		Sort(game.Property(setup.PropertyAuthorName), "asc").
+		Limit(500)
+
+	results := NewSmwQuery(client, askQuery)
 	authorsToProcess, err := fetchAllResultsFromSmwQuery(results)
 	if err != nil {
 		return authors, err
@@ -291,7 +596,7 @@ func GetAuthors(gameCode string, wikiConfig setup.WikiConfig) (authors []*Author
 				return authors, err
 			}
 
-			author, err := processAuthor(value)
+			author, err := processAuthor(game, value)
 			if err != nil {
 				return authors, err
 			}
@@ -299,6 +604,14 @@ func GetAuthors(gameCode string, wikiConfig setup.WikiConfig) (authors []*Author
 			authors = append(authors, author)
 		}
 	}
+
+	// api_version 2 results are keyed by page and lose the order of the query.
+	if wikiConfig.SmwApiVersion == 2 {
+		sort.SliceStable(authors, func(i, j int) bool {
+			return authors[i].Name < authors[j].Name
+		})
+	}
+
 	return authors, err
 }
 
@@ -306,7 +619,7 @@ func GetMaps(gameCode string, locationTitle string, wikiConfig setup.WikiConfig)
 	locationMaps = []*LocationMap{}
 	game, ok := wikiConfig.Games[gameCode]
 	if !ok {
-		return locationMaps, errors.New("game not supported")
+		return locationMaps, NewError(ErrGameNotSupported, "game not supported")
 	}
 
 	client, err := createClient()
@@ -314,17 +627,12 @@ func GetMaps(gameCode string, locationTitle string, wikiConfig setup.WikiConfig)
 		return locationMaps, err
 	}
 
-	conditions := fmt.Sprintf("%s:%s", game.Name, locationTitle)
-	printouts := "Has location map"
-
-	parameters := params.Values{
-		"conditions":  conditions,
-		"printouts":   printouts,
-		"format":      "json",
-		"api_version": "3",
-	}
+	askQuery := NewAskArgsQuery().
+		ApiVersion(wikiConfig.SmwApiVersion).
+		Page(game.Name + ":" + locationTitle).
+		Printout(game.Property(setup.PropertyLocationMap))
 
-	results := NewSmwQuery(client, parameters)
+	results := NewSmwQuery(client, askQuery)
 	locationsToProcess, err := fetchAllResultsFromSmwQuery(results)
 	if err != nil {
 		return locationMaps, err
@@ -337,26 +645,21 @@ func GetMaps(gameCode string, locationTitle string, wikiConfig setup.WikiConfig)
 				return locationMaps, err
 			}
 
-			printouts, err := value.GetObject("printouts")
-			if err != nil {
-				return nil, err
-			}
-
-			locationMapObjects, err := printouts.GetObjectArray("Has location map")
-			if err != nil {
-				log.Print("SERVER", "locationMaps", err.Error())
-				return nil, err
+			var location struct {
+				LocationMaps []*LocationMap `json:"locationMaps" smw:"location.map"`
 			}
 
-			maps, err := processLocationMaps(locationMapObjects)
+			err = DecodePrintouts(value, &location, game.Property)
 			if err != nil {
 				log.Print("SERVER", "locationMaps", err.Error())
 				return nil, err
 			}
 
-			locationMaps = maps
+			locationMaps = location.LocationMaps
 		}
 	}
+
+	err = resolveMapImages(client, wikiConfig, locationMaps)
 	return locationMaps, err
 }
 
@@ -364,7 +667,7 @@ func GetVendingMachines(gameCode string, wikiConfig setup.WikiConfig) (vendingMa
 	vendingMachines = []*VendingMachine{}
 	game, ok := wikiConfig.Games[gameCode]
 	if !ok {
-		return vendingMachines, errors.New("game not supported")
+		return vendingMachines, NewError(ErrGameNotSupported, "game not supported")
 	}
 
 	client, err := createClient()
@@ -372,24 +675,30 @@ func GetVendingMachines(gameCode string, wikiConfig setup.WikiConfig) (vendingMa
 		return vendingMachines, err
 	}
 
-	conditions := []string{fmt.Sprintf("-Has subobject::%s:Vending Machine", game.Name), "Vending Machine/Is implemented::true", "Vending Machine/Is accessible::true", "Vending Machine/Is secret::false"}
-	printouts := []string{"Has image path", "Vending Machine/Map ID", "Vending Machine/Event ID"}
-	queryParams := []string{"sort=Vending Machine/Location", "order=asc", "limit=500"}
+	askQuery := NewAskArgsQuery().
+		ApiVersion(wikiConfig.SmwApiVersion).
+		Property("-Has subobject", game.Name+":Vending Machine").
+		Property(game.Property(setup.PropertyVendingMachineIsImplemented), "true").
+		Property(game.Property(setup.PropertyVendingMachineIsAccessible), "true").
+		Property(game.Property(setup.PropertyVendingMachineIsSecret), "false").
+		Printout(game.Property(setup.PropertyVendingMachinePath), game.Property(setup.PropertyVendingMachineMapId), game.Property(setup.PropertyVendingMachineEventId)).
+		Sort(game.Property(setup.PropertyVendingMachineLocation), "asc").
+		Limit(500)
 
-	parameters := params.Values{
-		"conditions":  strings.Join(conditions, "|"),
-		"printouts":   strings.Join(printouts, "|"),
-		"parameters":  strings.Join(queryParams, "|"),
-		"format":      "json",
-		"api_version": "3",
+	// api_version 2 results are keyed by page and lose the order of the
+	// query, so they are sorted by location here instead.
+	unordered := wikiConfig.SmwApiVersion == 2
+	if unordered {
+		askQuery.Printout(game.Property(setup.PropertyVendingMachineLocation))
 	}
 
-	results := NewSmwQuery(client, parameters)
+	results := NewSmwQuery(client, askQuery)
 	vmsToProcess, err := fetchAllResultsFromSmwQuery(results)
 	if err != nil {
 		return vendingMachines, err
 	}
 
+	vmLocations := map[*VendingMachine]string{}
 	for _, vmToProcess := range vmsToProcess {
 		for _, value := range vmToProcess.Map() {
 			value, err := value.Object()
@@ -397,22 +706,38 @@ func GetVendingMachines(gameCode string, wikiConfig setup.WikiConfig) (vendingMa
 				return vendingMachines, err
 			}
 
-			vm, err := processVendingMachine(gameCode, value)
+			vm, err := processVendingMachine(gameCode, game, value)
 			if err != nil {
 				return vendingMachines, err
 			}
 
+			if unordered {
+				var sortKey struct {
+					Location string `smw:"vendingMachine.location,page"`
+				}
+				if err := DecodePrintouts(value, &sortKey, game.Property); err != nil {
+					return vendingMachines, err
+				}
+				vmLocations[vm] = sortKey.Location
+			}
+
 			vendingMachines = append(vendingMachines, vm)
 		}
 	}
 
+	if unordered {
+		sort.SliceStable(vendingMachines, func(i, j int) bool {
+			return vmLocations[vendingMachines[i]] < vmLocations[vendingMachines[j]]
+		})
+	}
+
 	return vendingMachines, err
 }
 
 func GetImages(gameParams GameParams, wikiConfig setup.WikiConfig) (images *LocationImages, err error) {
 	game, ok := wikiConfig.Games[gameParams.GameCode]
 	if !ok {
-		return images, errors.New("game not supported")
+		return images, NewError(ErrGameNotSupported, "game not supported")
 	}
 
 	images = &LocationImages{
@@ -441,7 +766,7 @@ func GetImages(gameParams GameParams, wikiConfig setup.WikiConfig) (images *Loca
 
 	query, err := client.Get(parameters)
 	if err != nil {
-		return images, err
+		return images, upstreamError(err)
 	}
 
 	continueKey, err := query.GetString("continue", "cmcontinue")
@@ -478,7 +803,7 @@ func GetImages(gameParams GameParams, wikiConfig setup.WikiConfig) (images *Loca
 
 		results, err := client.Get(parameters)
 		if err != nil {
-			return images, err
+			return images, upstreamError(err)
 		}
 
 		pageImagesToProcess, err := results.GetObjectArray("query", "pages")
@@ -538,457 +863,65 @@ func GetImages(gameParams GameParams, wikiConfig setup.WikiConfig) (images *Loca
 	return images, err
 }
 
-func processLocation(gameCode string, value *jason.Object) (location *Location, err error) {
-	printouts, err := value.GetObject("printouts")
-	if err != nil {
-		return nil, err
-	}
-
+func processLocation(game setup.Game, value *jason.Object, report *parseReport) (location *Location, err error) {
 	fulltext, err := value.GetString("fulltext")
 	if err != nil {
 		log.Print("SERVER", "fulltext", err.Error())
 		return nil, err
 	}
 
-	title := strings.Split(fulltext, ":")[1]
-
 	location = &Location{
-		Title:           title,
-		Game:            gameCode,
+		Title:           pageTitle(fulltext),
+		Game:            game.Name,
 		BGMs:            []*BGM{},
 		LocationMaps:    []*LocationMap{},
 		VersionsUpdated: []string{},
+		MapIds:          []int{},
 	}
 
-	locationImage, err := printouts.GetStringArray("Has location image")
-	if err != nil {
-		log.Print("SERVER", "locationImage", err.Error())
+	err = DecodePrintouts(value, location, game.Property)
+	if err := report.decoded(location.Title, err); err != nil {
 		return nil, err
 	}
 
-	if len(locationImage) > 0 {
-		location.LocationImage = locationImage[0]
-	}
-
-	headerBackgroundColor, err := printouts.GetStringArray("Header background color")
-	if err != nil {
-		log.Print("SERVER", "headerBackgroundColor", err.Error())
-		return nil, err
-	}
-
-	if len(headerBackgroundColor) > 0 {
-		location.BackgroundColor = headerBackgroundColor[0]
-	}
-
-	headerFontColor, err := printouts.GetStringArray("Header font color")
-	if err != nil {
-		log.Print("SERVER", "headerFontColor", err.Error())
-		return nil, err
-	}
-
-	if len(headerFontColor) > 0 {
-		location.FontColor = headerFontColor[0]
-	}
-
-	primaryAuthor, err := printouts.GetStringArray("Has primary author")
-	if err != nil {
-		log.Print("SERVER", "primaryAuthor", err.Error())
-		return nil, err
-	}
-
-	if len(primaryAuthor) > 0 {
-		location.PrimaryAuthor = strings.Join(primaryAuthor, ", ")
-	}
-
-	contributingAuthors, err := printouts.GetStringArray("Has contributing author")
-	if err != nil {
-		log.Print("SERVER", "contributingAuthors", err.Error())
-		return nil, err
-	}
-	location.ContributingAuthors = contributingAuthors
-
-	japaneseName, err := printouts.GetStringArray("Japanese name")
-	if err != nil {
-		log.Print("SERVER", "japaneseName", err.Error())
-		return nil, err
-	}
-
-	if len(japaneseName) > 0 {
-		location.OriginalName = japaneseName[0]
-	}
-
-	versionAdded, err := printouts.GetStringArray("Version added")
-	if err != nil {
-		log.Print("SERVER", "versionAdded", err.Error())
-		return nil, err
-	}
-
-	if len(versionAdded) > 0 {
-		location.VersionAdded = versionAdded[0]
-	}
-
-	versionsUpdated, err := printouts.GetStringArray("Versions updated")
-	if err != nil {
-		log.Print("SERVER", "versionUpdated", err.Error())
-		return nil, err
-	}
-
-	location.VersionsUpdated = versionsUpdated
-
-	versionRemoved, err := printouts.GetStringArray("Version removed")
-	if err != nil {
-		log.Print("SERVER", "versionRemoved", err.Error())
-		return nil, err
-	}
-
-	if len(versionRemoved) > 0 {
-		location.VersionRemoved = versionRemoved[0]
-	}
-
-	mapIdObjects, err := printouts.GetObjectArray("Map IDs")
-	if err != nil {
-		log.Print("SERVER", "mapIds", err.Error())
-		return nil, err
-	}
-
-	mapIds, err := processMapIdInfo(mapIdObjects)
-	if err != nil {
-		log.Print(title)
-		log.Print("SERVER", "mapIds", err.Error())
-		return nil, err
-	}
-
-	location.MapIds = mapIds
-
-	versionGaps, err := printouts.GetStringArray("Version gaps")
-	if err != nil {
-		log.Print("SERVER", "versionGaps", err.Error())
-		return nil, err
-	}
-
-	location.VersionGaps = versionGaps
-
-	bgmObjects, err := printouts.GetObjectArray("Has BGM")
-	if err != nil {
-		log.Print("SERVER", "bgms", err.Error())
-		return nil, err
-	}
-
-	bgms, err := processBGMs(bgmObjects)
-	if err != nil {
-		log.Print("SERVER", "bgms", err.Error())
-		return nil, err
-	}
-
-	if len(bgms) > 0 {
-		location.BGMs = bgms
-	}
-
-	locationMapObjects, err := printouts.GetObjectArray("Has location map")
-	if err != nil {
-		log.Print("SERVER", "locationMaps", err.Error())
-		return nil, err
-	}
-
-	locationMaps, err := processLocationMaps(locationMapObjects)
-	if err != nil {
-		log.Print("SERVER", "locationMaps", err.Error())
-		return nil, err
-	}
-
-	if len(locationMaps) > 0 {
-		location.LocationMaps = locationMaps
-	}
-
-	return location, err
-}
-
-func processMapIdInfo(mapIdObjects []*jason.Object) (mapIds []int, err error) {
-	mapIds = []int{}
-	for _, info := range mapIdObjects {
-		var mapId json.Number
-		outputMapId, err := info.GetNumberArray("Has map ID", "item")
-
-		if err != nil {
-			log.Print("SERVER", "mapId", err.Error())
-			return mapIds, err
-		}
-
-		if len(outputMapId) > 0 {
-			mapId = outputMapId[0]
-		}
-
-		data := mapId.String()
-
-		if id, err := strconv.Atoi(data); err == nil {
-			mapIds = append(mapIds, id)
-		}
-	}
-
-	return mapIds, nil
+	return location, nil
 }
 
-func processBGMs(bgmObjects []*jason.Object) (bgms []*BGM, err error) {
-	bgms = []*BGM{}
-	for _, bgm := range bgmObjects {
-		var bgmPath string
-		var bgmTitle string
-		var bgmLabel string
-		path, err := bgm.GetStringArray("Has media path", "item")
-		if err != nil {
-			return bgms, err
-		}
-
-		if len(path) > 0 {
-			bgmPath = path[0]
-		}
-
-		title, err := bgm.GetStringArray("BGM/Title", "item")
-		if err != nil {
-			return bgms, err
-		}
-
-		if len(title) > 0 {
-			bgmTitle = title[0]
-		}
-		label, err := bgm.GetStringArray("BGM/Label", "item")
-		if err != nil {
-			return bgms, err
-		}
-
-		if len(label) > 0 {
-			bgmLabel = label[0]
-		}
-		bgms = append(bgms, &BGM{
-			Path:  bgmPath,
-			Title: bgmTitle,
-			Label: bgmLabel,
-		})
-	}
-	return bgms, nil
-}
-
-func processLocationMaps(locationMapObjects []*jason.Object) (locationMaps []*LocationMap, err error) {
-	locationMaps = []*LocationMap{}
-	for _, locationMapObject := range locationMapObjects {
-		var locationMapPath string
-		var locationMapCaption string
-		path, err := locationMapObject.GetStringArray("Has image path", "item")
-		if err != nil {
-			return locationMaps, err
-		}
-		if len(path) > 0 {
-			locationMapPath = path[0]
-		}
-
-		caption, err := locationMapObject.GetStringArray("Location Map/Caption", "item")
-		if err != nil {
-			return locationMaps, err
-		}
-		if len(caption) > 0 {
-			locationMapCaption = caption[0]
-		}
-
-		locationMaps = append(locationMaps, &LocationMap{
-			Path:    locationMapPath,
-			Caption: locationMapCaption,
-		})
-	}
-	return locationMaps, nil
-}
-
-func processConnection(gameCode string, value *jason.Object) (connection *Connection, err error) {
-	printouts, err := value.GetObject("printouts")
-
-	if err != nil {
-		return connection, err
-	}
-
-	var origin string
-	var destination string
-
-	connectionOrigin, err := printouts.GetObjectArray("Connection/Origin")
-
-	if err != nil {
-		log.Print("SERVER", "origin", err.Error())
-		return connection, err
-	}
-
-	if len(connectionOrigin) > 0 {
-		originText, err := connectionOrigin[0].GetString("fulltext")
-		if err != nil {
-			log.Print("SERVER", "origin", err.Error())
-			return connection, err
-		}
-		origin = strings.Split(originText, ":")[1]
-	}
-
-	connectionDestination, err := printouts.GetObjectArray("Connection/Location")
-	if err != nil {
-		return connection, err
-	}
-
-	if len(connectionDestination) > 0 {
-		destinationText, err := connectionDestination[0].GetString("fulltext")
-		if err != nil {
-			log.Print("SERVER", "destination", err.Error())
-			return connection, err
-		}
-		destination = strings.Split(destinationText, ":")[1]
-	}
-
-	attributes, err := printouts.GetStringArray("Connection/Attribute")
-	if err != nil {
-		log.Print("SERVER", "attributes", err.Error())
-		return connection, err
-	}
-
-	unlockConditions, err := printouts.GetStringArray("Connection/Unlock conditions")
-	if err != nil {
-		log.Print("SERVER", "unlockConditions", err.Error())
-		return connection, err
-	}
-
-	effectsNeeded, err := printouts.GetStringArray("Connection/Effects needed")
-	if err != nil {
-		log.Print("SERVER", "effectsNeeded", err.Error())
-		return connection, err
-	}
-
-	seasonAvailable, err := printouts.GetStringArray("Connection/Season available")
-	if err != nil {
-		log.Print("SERVER", "seasonAvailable", err.Error())
-		return connection, err
-	}
-
-	chancePercentage, err := printouts.GetStringArray("Connection/Chance percentage")
-	if err != nil {
-		log.Print("SERVER", "chancePercentage", err.Error())
-		return connection, err
-	}
-
-	chanceDescription, err := printouts.GetStringArray("Connection/Chance description")
-	if err != nil {
-		log.Print("SERVER", "chanceDescription", err.Error())
-		return connection, err
-	}
-
-	isRemoved, err := printouts.GetStringArray("Connection/Is removed")
-	if err != nil {
-		log.Print("SERVER", "isRemoved", err.Error())
-		return connection, err
-	}
+func processConnection(gameCode string, game setup.Game, value *jason.Object, report *parseReport) (connection *Connection, err error) {
+	page, _ := value.GetString("fulltext")
 
 	connection = &Connection{
-		Game:        gameCode,
-		Origin:      origin,
-		Destination: destination,
-		Attributes:  attributes,
-	}
-
-	if len(isRemoved) > 0 && isRemoved[0] == "t" {
-		connection.IsRemoved = true
-	}
-
-	if len(unlockConditions) > 0 {
-		connection.UnlockConditions = unlockConditions[0]
-	}
-
-	if len(effectsNeeded) > 0 {
-		connection.EffectsNeeded = effectsNeeded
-	}
-
-	if len(seasonAvailable) > 0 {
-		connection.SeasonAvailable = seasonAvailable[0]
-	}
-
-	if len(chancePercentage) > 0 {
-		connection.ChancePercentage = chancePercentage[0]
+		Game: gameCode,
 	}
 
-	if len(chanceDescription) > 0 {
-		connection.ChanceDescription = chanceDescription[0]
+	err = DecodePrintouts(value, connection, game.Property)
+	if err := report.decoded(page, err); err != nil {
+		return nil, err
 	}
 
-	return connection, err
+	return connection, nil
 }
 
-func processAuthor(value *jason.Object) (author *Author, err error) {
+func processAuthor(game setup.Game, value *jason.Object) (author *Author, err error) {
 	author = &Author{}
-	printouts, err := value.GetObject("printouts")
-	if err != nil {
-		return nil, err
-	}
-
-	authorName, err := printouts.GetStringArray("Author/Name")
-	if err != nil {
-		log.Print("SERVER", "authorName", err.Error())
-		return nil, err
-	}
-
-	if len(authorName) > 0 {
-		author.Name = authorName[0]
-	}
-
-	originalNameObject, err := printouts.GetObjectArray("Author/Original Name")
+	err = DecodePrintouts(value, author, game.Property)
 	if err != nil {
-		log.Print("SERVER", "originalNameObject", err.Error())
+		log.Print("SERVER", "author", err.Error())
 		return nil, err
 	}
 
-	if len(originalNameObject) > 0 {
-		originalName, err := originalNameObject[0].GetStringArray("Text", "item")
-
-		if err != nil {
-			log.Print("SERVER", "originalName", err.Error())
-			return nil, err
-		}
-
-		if len(originalName) > 0 {
-			author.OriginalName = originalName[0]
-		}
-	}
-
 	return author, err
 }
 
-func processVendingMachine(gameCode string, value *jason.Object) (vendingMachine *VendingMachine, err error) {
+func processVendingMachine(gameCode string, game setup.Game, value *jason.Object) (vendingMachine *VendingMachine, err error) {
 	vendingMachine = &VendingMachine{
 		Game: gameCode,
 	}
-	printouts, err := value.GetObject("printouts")
-	if err != nil {
-		return nil, err
-	}
-
-	path, err := printouts.GetStringArray("Has image path")
-	if err != nil {
-		log.Print("SERVER", "path", err.Error())
-		return nil, err
-	}
-	if len(path) > 0 {
-		vendingMachine.Path = path[0]
-	}
-
-	mapId, err := printouts.GetStringArray("Vending Machine/Map ID")
+	err = DecodePrintouts(value, vendingMachine, game.Property)
 	if err != nil {
-		log.Print("SERVER", "mapId", err.Error())
+		log.Print("SERVER", "vendingMachine", err.Error())
 		return nil, err
 	}
 
-	if len(mapId) > 0 {
-		vendingMachine.MapId = mapId[0]
-	}
-
-	eventIds, err := printouts.GetStringArray("Vending Machine/Event ID")
-	if err != nil {
-		log.Print("SERVER", "eventIds", err.Error())
-		return nil, err
-	}
-
-	if len(eventIds) > 0 {
-		vendingMachine.EventIds = eventIds
-	}
-
 	return vendingMachine, err
 }
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+type ErrorCode string
+
+const (
+	ErrGameNotSupported    ErrorCode = "game_not_supported"
+	ErrProtagUnknown       ErrorCode = "protag_unknown"
+	ErrMissingParameter    ErrorCode = "missing_parameter"
+	ErrInvalidParameter    ErrorCode = "invalid_parameter"
+	ErrNotFound            ErrorCode = "not_found"
+	ErrUpstreamUnavailable ErrorCode = "upstream_unavailable"
+	ErrUpstreamTimeout     ErrorCode = "upstream_timeout"
+	ErrParseError          ErrorCode = "parse_error"
+	ErrInternal            ErrorCode = "internal_error"
+)
+
+var errorStatuses = map[ErrorCode]int{
+	ErrGameNotSupported:    http.StatusBadRequest,
+	ErrProtagUnknown:       http.StatusBadRequest,
+	ErrMissingParameter:    http.StatusBadRequest,
+	ErrInvalidParameter:    http.StatusBadRequest,
+	ErrNotFound:            http.StatusNotFound,
+	ErrUpstreamUnavailable: http.StatusBadGateway,
+	ErrUpstreamTimeout:     http.StatusGatewayTimeout,
+	ErrParseError:          http.StatusBadGateway,
+	ErrInternal:            http.StatusInternalServerError,
+}
+
+// Error is an error with a stable code clients can match on. Accepted lists
+// the valid values when the error is caused by an unknown parameter value.
+type Error struct {
+	Code     ErrorCode `json:"code"`
+	Message  string    `json:"message"`
+	Accepted []string  `json:"accepted,omitempty"`
+	Err      error     `json:"-"`
+}
+
+type ErrorResponse struct {
+	Error *Error `json:"error"`
+}
+
+func NewError(code ErrorCode, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status returns the HTTP status code the error is reported with.
+func (e *Error) Status() int {
+	status, ok := errorStatuses[e.Code]
+	if !ok {
+		return http.StatusInternalServerError
+	}
+	return status
+}
+
+// upstreamError wraps an error returned while calling the wiki API, telling
+// timeouts apart from other failures.
+func upstreamError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var wrapped *Error
+	if errors.As(err, &wrapped) {
+		return err
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &Error{Code: ErrUpstreamTimeout, Message: "wiki API timed out", Err: err}
+	}
+
+	return &Error{Code: ErrUpstreamUnavailable, Message: "wiki API unavailable: " + err.Error(), Err: err}
+}
+
+// AsError converts any error to an Error. Errors that were not classified
+// when they were returned come from reading the wiki response and are
+// reported as parse errors.
+func AsError(err error) *Error {
+	var wrapped *Error
+	if errors.As(err, &wrapped) {
+		return wrapped
+	}
+
+	return &Error{Code: ErrParseError, Message: "unexpected wiki response: " + err.Error(), Err: err}
+}
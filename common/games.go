@@ -0,0 +1,48 @@
+package common
+
+import (
+	"sort"
+
+	"github.com/ynoproject/wikiwrapper/setup"
+)
+
+type GameInfo struct {
+	Code         string        `json:"code"`
+	Name         string        `json:"name"`
+	Namespace    string        `json:"namespace"`
+	Protagonists []string      `json:"protagonists"`
+	Endpoints    GameEndpoints `json:"endpoints"`
+}
+
+type GameEndpoints struct {
+	Authors         bool `json:"authors"`
+	VendingMachines bool `json:"vms"`
+	Effects         bool `json:"effects"`
+	Images          bool `json:"images"`
+}
+
+// GetGames lists the games configured in the wiki config, ordered by code,
+// along with the endpoints that can serve them.
+func GetGames(wikiConfig setup.WikiConfig) []*GameInfo {
+	games := []*GameInfo{}
+	for code, game := range wikiConfig.Games {
+		games = append(games, &GameInfo{
+			Code:         code,
+			Name:         game.Name,
+			Namespace:    game.Namespace,
+			Protagonists: acceptedProtags(game),
+			Endpoints: GameEndpoints{
+				Authors:         game.HasFeature(setup.FeatureAuthors),
+				VendingMachines: game.HasFeature(setup.FeatureVendingMachines),
+				Effects:         game.HasFeature(setup.FeatureEffects),
+				Images:          game.HasFeature(setup.FeatureImages),
+			},
+		})
+	}
+
+	sort.Slice(games, func(i, j int) bool {
+		return games[i].Code < games[j].Code
+	})
+
+	return games
+}
@@ -0,0 +1,217 @@
+package common
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/ynoproject/wikiwrapper/setup"
+)
+
+// Graph is the directed world graph of a game, with locations as nodes and
+// connections as edges. Connection endpoints without a location page are
+// still included as nodes. Warnings lists the pages that could not be fully
+// parsed when the graph was built from the wiki.
+type Graph struct {
+	Game      string
+	Nodes     []string
+	Locations map[string]*Location
+	Edges     []*Connection
+	Warnings  []*Warning
+	outbound  map[string][]*Connection
+}
+
+type GraphAnalysis struct {
+	Game                        string         `json:"game"`
+	Hub                         string         `json:"hub"`
+	Distances                   map[string]int `json:"distances"`
+	Unreachable                 []string       `json:"unreachable"`
+	DeadEnds                    []string       `json:"deadEnds"`
+	StronglyConnectedComponents [][]string     `json:"stronglyConnectedComponents"`
+	ReachableOnlyViaRemoved     []string       `json:"reachableOnlyViaRemoved"`
+	Warnings                    []*Warning     `json:"warnings,omitempty"`
+}
+
+func NewGraph(gameCode string, locations []*Location, connections []*Connection) *Graph {
+	graph := &Graph{
+		Game:      gameCode,
+		Locations: map[string]*Location{},
+		outbound:  map[string][]*Connection{},
+	}
+
+	nodes := map[string]bool{}
+	for _, location := range locations {
+		graph.Locations[location.Title] = location
+		nodes[location.Title] = true
+	}
+
+	for _, connection := range connections {
+		if connection.Origin == "" || connection.Destination == "" {
+			continue
+		}
+
+		nodes[connection.Origin] = true
+		nodes[connection.Destination] = true
+		graph.Edges = append(graph.Edges, connection)
+		graph.outbound[connection.Origin] = append(graph.outbound[connection.Origin], connection)
+	}
+
+	for node := range nodes {
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	sort.Strings(graph.Nodes)
+
+	return graph
+}
+
+// Outbound returns the connections leaving a location. Removed connections
+// are only included when includeRemoved is set.
+func (g *Graph) Outbound(title string, includeRemoved bool) []*Connection {
+	var connections []*Connection
+	for _, connection := range g.outbound[title] {
+		if connection.IsRemoved && !includeRemoved {
+			continue
+		}
+		connections = append(connections, connection)
+	}
+	return connections
+}
+
+// Distances runs a breadth-first search from the given location and returns
+// the number of connections needed to reach every reachable location.
+func (g *Graph) Distances(from string, includeRemoved bool) map[string]int {
+	distances := map[string]int{from: 0}
+	queue := []string{from}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		for _, connection := range g.Outbound(current, includeRemoved) {
+			if _, seen := distances[connection.Destination]; seen {
+				continue
+			}
+			distances[connection.Destination] = distances[current] + 1
+			queue = append(queue, connection.Destination)
+		}
+	}
+
+	return distances
+}
+
+// StronglyConnectedComponents returns the components of the graph, ignoring
+// removed connections, using Tarjan's algorithm. Components made of a single
+// location are left out; the largest components come first.
+func (g *Graph) StronglyConnectedComponents() [][]string {
+	index := 0
+	indices := map[string]int{}
+	lowLinks := map[string]int{}
+	onStack := map[string]bool{}
+	stack := []string{}
+	components := [][]string{}
+
+	var connect func(node string)
+	connect = func(node string) {
+		indices[node] = index
+		lowLinks[node] = index
+		index++
+		stack = append(stack, node)
+		onStack[node] = true
+
+		for _, connection := range g.Outbound(node, false) {
+			next := connection.Destination
+			if _, visited := indices[next]; !visited {
+				connect(next)
+				lowLinks[node] = min(lowLinks[node], lowLinks[next])
+			} else if onStack[next] {
+				lowLinks[node] = min(lowLinks[node], indices[next])
+			}
+		}
+
+		if lowLinks[node] != indices[node] {
+			return
+		}
+
+		var component []string
+		for {
+			last := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[last] = false
+			component = append(component, last)
+			if last == node {
+				break
+			}
+		}
+
+		if len(component) > 1 {
+			sort.Strings(component)
+			components = append(components, component)
+		}
+	}
+
+	for _, node := range g.Nodes {
+		if _, visited := indices[node]; !visited {
+			connect(node)
+		}
+	}
+
+	sort.SliceStable(components, func(i, j int) bool {
+		if len(components[i]) != len(components[j]) {
+			return len(components[i]) > len(components[j])
+		}
+		return components[i][0] < components[j][0]
+	})
+
+	return components
+}
+
+// Analyze computes reachability information relative to a hub location.
+// Locations only reachable through removed connections are reported
+// separately from locations that cannot be reached at all.
+func (g *Graph) Analyze(hub string) *GraphAnalysis {
+	analysis := &GraphAnalysis{
+		Game:                        g.Game,
+		Hub:                         hub,
+		Distances:                   g.Distances(hub, false),
+		Unreachable:                 []string{},
+		DeadEnds:                    []string{},
+		StronglyConnectedComponents: g.StronglyConnectedComponents(),
+		ReachableOnlyViaRemoved:     []string{},
+		Warnings:                    g.Warnings,
+	}
+
+	distancesWithRemoved := g.Distances(hub, true)
+
+	for _, node := range g.Nodes {
+		if _, reachable := analysis.Distances[node]; !reachable {
+			if _, reachableWithRemoved := distancesWithRemoved[node]; reachableWithRemoved {
+				analysis.ReachableOnlyViaRemoved = append(analysis.ReachableOnlyViaRemoved, node)
+			} else {
+				analysis.Unreachable = append(analysis.Unreachable, node)
+			}
+		}
+
+		if len(g.Outbound(node, false)) == 0 {
+			analysis.DeadEnds = append(analysis.DeadEnds, node)
+		}
+	}
+
+	return analysis
+}
+
+// GetGraph builds the world graph of a game from its cached locations and
+// connections.
+func GetGraph(gameCode string, wikiConfig setup.WikiConfig) (graph *Graph, err error) {
+	locations, locationWarnings, err := CachedLocations(gameCode, wikiConfig)
+	if err != nil {
+		return graph, err
+	}
+
+	connections, connectionWarnings, err := CachedConnections(gameCode, wikiConfig)
+	if err != nil {
+		return graph, err
+	}
+
+	graph = NewGraph(gameCode, locations, connections)
+	graph.Warnings = append(slices.Clone(locationWarnings), connectionWarnings...)
+	return graph, nil
+}
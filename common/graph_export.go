@@ -0,0 +1,228 @@
+package common
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+type CytoscapeGraph struct {
+	Elements CytoscapeElements `json:"elements"`
+}
+
+type CytoscapeElements struct {
+	Nodes []*CytoscapeElement `json:"nodes"`
+	Edges []*CytoscapeElement `json:"edges"`
+}
+
+type CytoscapeElement struct {
+	Data map[string]interface{} `json:"data"`
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	Id       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	Id          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	Id   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Id     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+var graphMLKeys = []graphMLKey{
+	{Id: "backgroundColor", For: "node", AttrName: "backgroundColor", AttrType: "string"},
+	{Id: "fontColor", For: "node", AttrName: "fontColor", AttrType: "string"},
+	{Id: "locationImage", For: "node", AttrName: "locationImage", AttrType: "string"},
+	{Id: "versionAdded", For: "node", AttrName: "versionAdded", AttrType: "string"},
+	{Id: "attributes", For: "edge", AttrName: "attributes", AttrType: "string"},
+	{Id: "chancePercentage", For: "edge", AttrName: "chancePercentage", AttrType: "string"},
+	{Id: "seasonAvailable", For: "edge", AttrName: "seasonAvailable", AttrType: "string"},
+	{Id: "isRemoved", For: "edge", AttrName: "isRemoved", AttrType: "boolean"},
+}
+
+// nodeAttributes returns the exported attributes of a node, skipping the
+// ones that are empty or whose location has no page.
+func (g *Graph) nodeAttributes(title string) [][2]string {
+	location, ok := g.Locations[title]
+	if !ok {
+		return nil
+	}
+
+	var attributes [][2]string
+	for _, attribute := range [][2]string{
+		{"backgroundColor", location.BackgroundColor},
+		{"fontColor", location.FontColor},
+		{"locationImage", location.LocationImage},
+		{"versionAdded", location.VersionAdded},
+	} {
+		if attribute[1] != "" {
+			attributes = append(attributes, attribute)
+		}
+	}
+	return attributes
+}
+
+func edgeAttributes(connection *Connection) [][2]string {
+	var attributes [][2]string
+	for _, attribute := range [][2]string{
+		{"attributes", strings.Join(connection.Attributes, ",")},
+		{"chancePercentage", connection.ChancePercentage},
+		{"seasonAvailable", connection.SeasonAvailable},
+	} {
+		if attribute[1] != "" {
+			attributes = append(attributes, attribute)
+		}
+	}
+	if connection.IsRemoved {
+		attributes = append(attributes, [2]string{"isRemoved", "true"})
+	}
+	return attributes
+}
+
+func quoteDOT(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return `"` + value + `"`
+}
+
+// ExportDOT renders the graph in the GraphViz DOT language. Location colors
+// are mapped to fillcolor and fontcolor so the output renders as-is.
+func (g *Graph) ExportDOT() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "digraph %s {\n", quoteDOT(g.Game))
+	for _, node := range g.Nodes {
+		attributes := []string{"label=" + quoteDOT(node)}
+		for _, attribute := range g.nodeAttributes(node) {
+			switch attribute[0] {
+			case "backgroundColor":
+				attributes = append(attributes, "style=filled", "fillcolor="+quoteDOT(attribute[1]))
+			case "fontColor":
+				attributes = append(attributes, "fontcolor="+quoteDOT(attribute[1]))
+			default:
+				attributes = append(attributes, attribute[0]+"="+quoteDOT(attribute[1]))
+			}
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", quoteDOT(node), strings.Join(attributes, ", "))
+	}
+
+	for _, edge := range g.Edges {
+		var attributes []string
+		for _, attribute := range edgeAttributes(edge) {
+			attributes = append(attributes, attribute[0]+"="+quoteDOT(attribute[1]))
+		}
+		if edge.IsRemoved {
+			attributes = append(attributes, "style=dashed")
+		}
+		fmt.Fprintf(&b, "  %s -> %s [%s];\n", quoteDOT(edge.Origin), quoteDOT(edge.Destination), strings.Join(attributes, ", "))
+	}
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+// ExportGraphML renders the graph as a GraphML document.
+func (g *Graph) ExportGraphML() ([]byte, error) {
+	document := graphMLDocument{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys:  graphMLKeys,
+		Graph: graphMLGraph{
+			Id:          g.Game,
+			EdgeDefault: "directed",
+		},
+	}
+
+	for _, node := range g.Nodes {
+		graphMLNode := graphMLNode{Id: node}
+		for _, attribute := range g.nodeAttributes(node) {
+			graphMLNode.Data = append(graphMLNode.Data, graphMLData{Key: attribute[0], Value: attribute[1]})
+		}
+		document.Graph.Nodes = append(document.Graph.Nodes, graphMLNode)
+	}
+
+	for i, edge := range g.Edges {
+		graphMLEdge := graphMLEdge{
+			Id:     fmt.Sprintf("e%d", i),
+			Source: edge.Origin,
+			Target: edge.Destination,
+		}
+		for _, attribute := range edgeAttributes(edge) {
+			graphMLEdge.Data = append(graphMLEdge.Data, graphMLData{Key: attribute[0], Value: attribute[1]})
+		}
+		document.Graph.Edges = append(document.Graph.Edges, graphMLEdge)
+	}
+
+	output, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), output...), nil
+}
+
+// ExportCytoscape converts the graph to the Cytoscape.js elements format.
+func (g *Graph) ExportCytoscape() *CytoscapeGraph {
+	graph := &CytoscapeGraph{
+		Elements: CytoscapeElements{
+			Nodes: []*CytoscapeElement{},
+			Edges: []*CytoscapeElement{},
+		},
+	}
+
+	for _, node := range g.Nodes {
+		data := map[string]interface{}{"id": node, "label": node}
+		for _, attribute := range g.nodeAttributes(node) {
+			data[attribute[0]] = attribute[1]
+		}
+		graph.Elements.Nodes = append(graph.Elements.Nodes, &CytoscapeElement{Data: data})
+	}
+
+	for i, edge := range g.Edges {
+		data := map[string]interface{}{
+			"id":     fmt.Sprintf("e%d", i),
+			"source": edge.Origin,
+			"target": edge.Destination,
+		}
+		for _, attribute := range edgeAttributes(edge) {
+			data[attribute[0]] = attribute[1]
+		}
+		if len(edge.Attributes) > 0 {
+			data["attributes"] = edge.Attributes
+		}
+		if edge.IsRemoved {
+			data["isRemoved"] = true
+		}
+		graph.Elements.Edges = append(graph.Elements.Edges, &CytoscapeElement{Data: data})
+	}
+
+	return graph
+}
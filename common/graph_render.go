@@ -0,0 +1,259 @@
+package common
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"strings"
+)
+
+type Point struct {
+	X, Y float64
+}
+
+const (
+	layoutIterations = 300
+	layoutNodeWidth  = 140.0
+	layoutNodeHeight = 28.0
+	layoutSpacing    = 120.0
+	layoutMargin     = 40.0
+
+	// MaxRenderNodes is the largest graph that is laid out and rendered.
+	MaxRenderNodes = 150
+)
+
+// Subgraph returns the part of the graph within depth connections of the
+// center location, following connections in both directions.
+func (g *Graph) Subgraph(center string, depth int) *Graph {
+	neighbours := map[string][]string{}
+	for _, edge := range g.Edges {
+		neighbours[edge.Origin] = append(neighbours[edge.Origin], edge.Destination)
+		neighbours[edge.Destination] = append(neighbours[edge.Destination], edge.Origin)
+	}
+
+	distances := map[string]int{center: 0}
+	queue := []string{center}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if distances[current] >= depth {
+			continue
+		}
+
+		for _, neighbour := range neighbours[current] {
+			if _, seen := distances[neighbour]; seen {
+				continue
+			}
+			distances[neighbour] = distances[current] + 1
+			queue = append(queue, neighbour)
+		}
+	}
+
+	var locations []*Location
+	for title := range distances {
+		if location, ok := g.Locations[title]; ok {
+			locations = append(locations, location)
+		} else {
+			locations = append(locations, &Location{Title: title})
+		}
+	}
+
+	var connections []*Connection
+	for _, edge := range g.Edges {
+		_, hasOrigin := distances[edge.Origin]
+		_, hasDestination := distances[edge.Destination]
+		if hasOrigin && hasDestination {
+			connections = append(connections, edge)
+		}
+	}
+
+	return NewGraph(g.Game, locations, connections)
+}
+
+// Layout places the nodes with a Fruchterman-Reingold force-directed
+// layout. Nodes start on a spiral in title order so the result is
+// deterministic for a given graph. Every iteration compares all pairs of
+// nodes, so callers should keep graphs under MaxRenderNodes.
+func (g *Graph) Layout() map[string]Point {
+	positions := map[string]Point{}
+	count := len(g.Nodes)
+	if count == 0 {
+		return positions
+	}
+
+	index := make(map[string]int, count)
+	for i, node := range g.Nodes {
+		index[node] = i
+	}
+
+	type edge struct{ origin, destination int }
+	edges := make([]edge, 0, len(g.Edges))
+	for _, e := range g.Edges {
+		origin, hasOrigin := index[e.Origin]
+		destination, hasDestination := index[e.Destination]
+		if hasOrigin && hasDestination && origin != destination {
+			edges = append(edges, edge{origin, destination})
+		}
+	}
+
+	area := float64(count) * layoutSpacing * layoutSpacing
+	k := math.Sqrt(area / float64(count))
+	radius := math.Sqrt(area) / 2
+
+	points := make([]Point, count)
+	goldenAngle := math.Pi * (3 - math.Sqrt(5))
+	for i := range points {
+		angle := goldenAngle * float64(i)
+		distance := radius * math.Sqrt(float64(i+1)/float64(count))
+		points[i] = Point{X: distance * math.Cos(angle), Y: distance * math.Sin(angle)}
+	}
+
+	displacements := make([]Point, count)
+	temperature := radius / 2
+	for iteration := 0; iteration < layoutIterations; iteration++ {
+		clear(displacements)
+
+		for v := 0; v < count; v++ {
+			for u := v + 1; u < count; u++ {
+				dx := points[v].X - points[u].X
+				dy := points[v].Y - points[u].Y
+				distance := math.Max(math.Hypot(dx, dy), 0.01)
+				force := k * k / distance
+
+				displacements[v].X += dx / distance * force
+				displacements[v].Y += dy / distance * force
+				displacements[u].X -= dx / distance * force
+				displacements[u].Y -= dy / distance * force
+			}
+		}
+
+		for _, e := range edges {
+			dx := points[e.origin].X - points[e.destination].X
+			dy := points[e.origin].Y - points[e.destination].Y
+			distance := math.Max(math.Hypot(dx, dy), 0.01)
+			force := distance * distance / k
+
+			displacements[e.origin].X -= dx / distance * force
+			displacements[e.origin].Y -= dy / distance * force
+			displacements[e.destination].X += dx / distance * force
+			displacements[e.destination].Y += dy / distance * force
+		}
+
+		for i, displacement := range displacements {
+			length := math.Max(math.Hypot(displacement.X, displacement.Y), 0.01)
+			step := math.Min(length, temperature)
+
+			points[i].X += displacement.X / length * step
+			points[i].Y += displacement.Y / length * step
+		}
+
+		temperature = radius / 2 * (1 - float64(iteration+1)/float64(layoutIterations))
+	}
+
+	for i, node := range g.Nodes {
+		positions[node] = points[i]
+	}
+	return positions
+}
+
+func svgColor(color string, fallback string) string {
+	color = strings.TrimSpace(color)
+	if !IsValidColor(color) {
+		return fallback
+	}
+	return color
+}
+
+func escapeSVG(value string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
+
+// RenderSVG lays out the graph and draws it as an SVG document, filling each
+// node with its location's header colors. Removed connections are dashed.
+func (g *Graph) RenderSVG() string {
+	positions := g.Layout()
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, position := range positions {
+		minX, minY = math.Min(minX, position.X), math.Min(minY, position.Y)
+		maxX, maxY = math.Max(maxX, position.X), math.Max(maxY, position.Y)
+	}
+	if len(positions) == 0 {
+		minX, minY, maxX, maxY = 0, 0, 0, 0
+	}
+
+	offsetX := layoutMargin + layoutNodeWidth/2 - minX
+	offsetY := layoutMargin + layoutNodeHeight/2 - minY
+	width := maxX - minX + layoutNodeWidth + 2*layoutMargin
+	height := maxY - minY + layoutNodeHeight + 2*layoutMargin
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f">`+"\n", width, height, width, height)
+	b.WriteString(`<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="6" markerHeight="6" orient="auto-start-reverse"><path d="M 0 0 L 10 5 L 0 10 z" fill="#888"/></marker></defs>` + "\n")
+
+	for _, edge := range g.Edges {
+		if edge.Origin == edge.Destination {
+			continue
+		}
+
+		from := positions[edge.Origin]
+		to := positions[edge.Destination]
+		x1, y1 := clipToNode(from, to)
+		x2, y2 := clipToNode(to, from)
+
+		dash := ""
+		if edge.IsRemoved {
+			dash = ` stroke-dasharray="4 4"`
+		}
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#888"%s marker-end="url(#arrow)"/>`+"\n", x1+offsetX, y1+offsetY, x2+offsetX, y2+offsetY, dash)
+	}
+
+	for _, node := range g.Nodes {
+		backgroundColor, fontColor := "#ffffff", "#000000"
+		if location, ok := g.Locations[node]; ok {
+			backgroundColor = svgColor(location.BackgroundColor, backgroundColor)
+			fontColor = svgColor(location.FontColor, fontColor)
+		}
+
+		position := positions[node]
+		x := position.X + offsetX
+		y := position.Y + offsetY
+		fmt.Fprintf(&b, `<g><title>%s</title>`, escapeSVG(node))
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.0f" height="%.0f" rx="6" fill="%s" stroke="#444"/>`, x-layoutNodeWidth/2, y-layoutNodeHeight/2, layoutNodeWidth, layoutNodeHeight, backgroundColor)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" fill="%s" font-family="sans-serif" font-size="11" text-anchor="middle" dominant-baseline="central">%s</text></g>`+"\n", x, y, fontColor, escapeSVG(truncateLabel(node, 22)))
+	}
+
+	b.WriteString("</svg>\n")
+	return b.String()
+}
+
+// clipToNode moves the start of a line from the center of a node to the
+// border of its box, so arrow heads stay visible.
+func clipToNode(from Point, to Point) (float64, float64) {
+	dx, dy := to.X-from.X, to.Y-from.Y
+	if dx == 0 && dy == 0 {
+		return from.X, from.Y
+	}
+
+	scale := math.Min(
+		math.Abs(layoutNodeWidth/2/dx),
+		math.Abs(layoutNodeHeight/2/dy),
+	)
+	if scale > 1 {
+		scale = 1
+	}
+
+	return from.X + dx*scale, from.Y + dy*scale
+}
+
+func truncateLabel(label string, length int) string {
+	runes := []rune(label)
+	if len(runes) <= length {
+		return label
+	}
+	return string(runes[:length-1]) + "…"
+}
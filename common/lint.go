@@ -0,0 +1,148 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ynoproject/wikiwrapper/setup"
+)
+
+const (
+	LintMissingLocation = "missing_location"
+	LintNoMapIds        = "no_map_ids"
+	LintDuplicateMapId  = "duplicate_map_id"
+	LintUnknownVmMap    = "unknown_vm_map"
+	LintMissingImage    = "missing_image"
+	LintMalformedColor  = "malformed_color"
+)
+
+type LintIssue struct {
+	Check   string `json:"check"`
+	Subject string `json:"subject"`
+	Message string `json:"message"`
+}
+
+// LintReport lists the issues found in the data of a game. Warnings lists
+// the pages that could not be parsed at all or only partly; they are left out
+// of the checks, or checked with their unparsed fields empty.
+type LintReport struct {
+	Game     string       `json:"game"`
+	Issues   []*LintIssue `json:"issues"`
+	Warnings []*Warning   `json:"warnings"`
+}
+
+func (r *LintReport) add(check string, subject string, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, &LintIssue{
+		Check:   check,
+		Subject: subject,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// LintData cross-checks the locations, connections and vending machines of a
+// game and reports inconsistencies wiki editors should fix. Issues are
+// grouped by check and ordered by subject.
+func LintData(gameCode string, locations []*Location, connections []*Connection, vendingMachines []*VendingMachine) *LintReport {
+	report := &LintReport{
+		Game:     gameCode,
+		Issues:   []*LintIssue{},
+		Warnings: []*Warning{},
+	}
+
+	locationTitles := map[string]bool{}
+	mapIdLocations := map[int][]string{}
+	for _, location := range locations {
+		locationTitles[location.Title] = true
+		for _, mapId := range location.MapIds {
+			mapIdLocations[mapId] = append(mapIdLocations[mapId], location.Title)
+		}
+	}
+
+	missingLocations := map[string]bool{}
+	for _, connection := range connections {
+		for _, title := range []string{connection.Origin, connection.Destination} {
+			if title != "" && !locationTitles[title] && !missingLocations[title] {
+				missingLocations[title] = true
+				report.add(LintMissingLocation, title, "connection %s -> %s refers to a location without a page", connection.Origin, connection.Destination)
+			}
+		}
+	}
+
+	for _, location := range locations {
+		if len(location.MapIds) == 0 {
+			report.add(LintNoMapIds, location.Title, "location has no map IDs")
+		}
+
+		if location.LocationImage == "" {
+			report.add(LintMissingImage, location.Title, "location has no location image")
+		}
+
+		if location.BackgroundColor != "" && !IsValidColor(location.BackgroundColor) {
+			report.add(LintMalformedColor, location.Title, "header background color %q is not a valid color", location.BackgroundColor)
+		}
+
+		if location.FontColor != "" && !IsValidColor(location.FontColor) {
+			report.add(LintMalformedColor, location.Title, "header font color %q is not a valid color", location.FontColor)
+		}
+	}
+
+	mapIds := make([]int, 0, len(mapIdLocations))
+	for mapId := range mapIdLocations {
+		mapIds = append(mapIds, mapId)
+	}
+	sort.Ints(mapIds)
+
+	for _, mapId := range mapIds {
+		titles := mapIdLocations[mapId]
+		if len(titles) > 1 {
+			report.add(LintDuplicateMapId, strconv.Itoa(mapId), "map ID is used by several locations: %s", strings.Join(titles, ", "))
+		}
+	}
+
+	for _, vendingMachine := range vendingMachines {
+		mapId, err := strconv.Atoi(strings.TrimSpace(vendingMachine.MapId))
+		if err != nil {
+			report.add(LintUnknownVmMap, vendingMachine.Path, "vending machine map ID %q is not a number", vendingMachine.MapId)
+			continue
+		}
+
+		if _, ok := mapIdLocations[mapId]; !ok {
+			report.add(LintUnknownVmMap, vendingMachine.Path, "vending machine is on map %d, which no location lists", mapId)
+		}
+	}
+
+	sort.SliceStable(report.Issues, func(i, j int) bool {
+		if report.Issues[i].Check != report.Issues[j].Check {
+			return report.Issues[i].Check < report.Issues[j].Check
+		}
+		return report.Issues[i].Subject < report.Issues[j].Subject
+	})
+
+	return report
+}
+
+// Lint checks the cached locations and connections of a game, so edits show
+// up in the report once the cache has been refreshed.
+func Lint(gameCode string, wikiConfig setup.WikiConfig) (report *LintReport, err error) {
+	locations, locationWarnings, err := CachedLocations(gameCode, wikiConfig)
+	if err != nil {
+		return report, err
+	}
+
+	connections, connectionWarnings, err := CachedConnections(gameCode, wikiConfig)
+	if err != nil {
+		return report, err
+	}
+
+	vendingMachines, err := GetVendingMachines(gameCode, wikiConfig)
+	if err != nil {
+		return report, err
+	}
+
+	report = LintData(gameCode, locations, connections, vendingMachines)
+	report.Warnings = append(report.Warnings, locationWarnings...)
+	report.Warnings = append(report.Warnings, connectionWarnings...)
+	return report, nil
+}
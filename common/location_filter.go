@@ -0,0 +1,216 @@
+package common
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ynoproject/wikiwrapper/setup"
+)
+
+const (
+	LocationSortTitle        = "title"
+	LocationSortVersionAdded = "versionAdded"
+)
+
+// LocationSorts lists the accepted sort keys; a leading "-" reverses them.
+var LocationSorts = []string{LocationSortTitle, LocationSortVersionAdded}
+
+// LocationFilter selects locations by their properties. Empty and nil fields
+// do not filter. Version bounds are inclusive.
+type LocationFilter struct {
+	Author             string
+	VersionAddedFrom   string
+	VersionAddedTo     string
+	VersionRemovedFrom string
+	VersionRemovedTo   string
+	HasBGM             *bool
+	HasMap             *bool
+	Removed            *bool
+	MapId              *int
+	AsOfVersion        string
+	Sort               string
+}
+
+// IsEmpty reports whether the filter neither filters nor sorts.
+func (f LocationFilter) IsEmpty() bool {
+	return f == LocationFilter{}
+}
+
+func (f LocationFilter) Match(location *Location) bool {
+	if f.Author != "" && !hasAuthor(location, f.Author) {
+		return false
+	}
+
+	if !versionInRange(location.VersionAdded, f.VersionAddedFrom, f.VersionAddedTo) {
+		return false
+	}
+
+	if !versionInRange(location.VersionRemoved, f.VersionRemovedFrom, f.VersionRemovedTo) {
+		return false
+	}
+
+	if f.HasBGM != nil && (len(location.BGMs) > 0) != *f.HasBGM {
+		return false
+	}
+
+	if f.HasMap != nil && (len(location.LocationMaps) > 0) != *f.HasMap {
+		return false
+	}
+
+	if f.Removed != nil && (location.VersionRemoved != "") != *f.Removed {
+		return false
+	}
+
+	if f.MapId != nil && !slices.Contains(location.MapIds, *f.MapId) {
+		return false
+	}
+
+	if f.AsOfVersion != "" {
+		version, err := ParseVersion(f.AsOfVersion)
+		if err != nil || !location.PresentIn(version) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hasAuthor(location *Location, author string) bool {
+	for _, primaryAuthor := range strings.Split(location.PrimaryAuthor, ", ") {
+		if strings.EqualFold(primaryAuthor, author) {
+			return true
+		}
+	}
+
+	for _, contributingAuthor := range location.ContributingAuthors {
+		if strings.EqualFold(contributingAuthor, author) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// versionInRange reports whether a version lies within the bounds. A
+// location without the version is outside any bounded range.
+func versionInRange(version string, from string, to string) bool {
+	if from == "" && to == "" {
+		return true
+	}
+
+	if version == "" {
+		return false
+	}
+
+	if from != "" && compareVersions(version, from) < 0 {
+		return false
+	}
+
+	return to == "" || compareVersions(version, to) <= 0
+}
+
+// FilterLocations returns the locations matching the filter, in the order of
+// its sort key.
+func FilterLocations(locations []*Location, filter LocationFilter) []*Location {
+	filtered := []*Location{}
+	for _, location := range locations {
+		if filter.Match(location) {
+			filtered = append(filtered, location)
+		}
+	}
+
+	sortKey, descending := strings.CutPrefix(filter.Sort, "-")
+	var less func(a, b *Location) bool
+	switch sortKey {
+	case LocationSortTitle:
+		less = func(a, b *Location) bool { return a.Title < b.Title }
+	case LocationSortVersionAdded:
+		less = func(a, b *Location) bool {
+			if c := compareVersions(a.VersionAdded, b.VersionAdded); c != 0 {
+				return c < 0
+			}
+			return a.Title < b.Title
+		}
+	default:
+		return filtered
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if descending {
+			return less(filtered[j], filtered[i])
+		}
+		return less(filtered[i], filtered[j])
+	})
+
+	return filtered
+}
+
+// GetFilteredLocations filters and sorts the whole location set of a game.
+// Results are not paged. For games with several protagonists, only the
+// locations of the requested protagonist are kept unless it is empty or
+// AllProtags.
+func GetFilteredLocations(gameParams GameParams, filter LocationFilter, wikiConfig setup.WikiConfig) (locations *Locations, err error) {
+	game, ok := wikiConfig.Games[gameParams.GameCode]
+	if !ok {
+		return locations, NewError(ErrGameNotSupported, "game not supported")
+	}
+
+	hasMultipleProtags := len(game.Protagonists) > 0
+	allProtags := gameParams.Protag == "" || gameParams.Protag == AllProtags
+
+	if !hasMultipleProtags && !allProtags {
+		return locations, NewError(ErrProtagUnknown, "game has only one protagonist")
+	}
+
+	if sortKey := strings.TrimPrefix(filter.Sort, "-"); filter.Sort != "" && !slices.Contains(LocationSorts, sortKey) {
+		return locations, &Error{
+			Code:     ErrInvalidParameter,
+			Message:  "sort key not supported",
+			Accepted: LocationSorts,
+		}
+	}
+
+	for _, bound := range []string{filter.VersionAddedFrom, filter.VersionAddedTo, filter.VersionRemovedFrom, filter.VersionRemovedTo, filter.AsOfVersion} {
+		if _, err := ParseVersion(bound); bound != "" && err != nil {
+			return locations, &Error{Code: ErrInvalidParameter, Message: err.Error(), Err: err}
+		}
+	}
+
+	locations = &Locations{
+		Game:    gameParams.GameCode,
+		Protags: []string{},
+	}
+
+	if hasMultipleProtags && allProtags {
+		locations.Protags = acceptedProtags(game)
+	} else if hasMultipleProtags {
+		if _, ok := game.Protagonists[gameParams.Protag]; !ok {
+			return locations, &Error{
+				Code:     ErrProtagUnknown,
+				Message:  "protagonist does not exist or is misspelled",
+				Accepted: acceptedProtags(game),
+			}
+		}
+		locations.Protags = []string{gameParams.Protag}
+	}
+
+	allLocations, warnings, err := CachedLocations(gameParams.GameCode, wikiConfig)
+	if err != nil {
+		return locations, err
+	}
+	locations.Warnings = warnings
+
+	if hasMultipleProtags && !allProtags {
+		protagLocations := []*Location{}
+		for _, location := range allLocations {
+			if slices.Contains(location.Protags, gameParams.Protag) {
+				protagLocations = append(protagLocations, location)
+			}
+		}
+		allLocations = protagLocations
+	}
+
+	locations.Locations = FilterLocations(allLocations, filter)
+	return locations, nil
+}
@@ -0,0 +1,235 @@
+package common
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cgt.name/pkg/go-mwclient"
+	"cgt.name/pkg/go-mwclient/params"
+	"github.com/ynoproject/wikiwrapper/setup"
+)
+
+// imageInfoBatchSize is the number of titles the API accepts per request.
+const imageInfoBatchSize = 50
+
+var (
+	imageInfoMutex sync.Mutex
+	imageInfos     = map[string]*imageInfoEntry{}
+)
+
+// imageInfoEntry caches the metadata of a file. A nil image records that the
+// file does not exist.
+type imageInfoEntry struct {
+	image     *MapImage
+	fetchedAt time.Time
+}
+
+// LocationMaps are the maps of one location.
+type LocationMaps struct {
+	Title        string         `json:"title"`
+	LocationMaps []*LocationMap `json:"locationMaps"`
+}
+
+type MapCatalog struct {
+	Game      string          `json:"game"`
+	Locations []*LocationMaps `json:"locations"`
+}
+
+// fileTitle returns the page title of a file path.
+func fileTitle(path string) string {
+	path = strings.TrimSpace(path)
+	if namespace, name, found := strings.Cut(path, ":"); found && strings.EqualFold(namespace, "File") {
+		path = name
+	}
+	return "File:" + strings.ReplaceAll(path, "_", " ")
+}
+
+// fetchImageInfo returns the metadata of files, keyed by file title. Files
+// whose metadata is older than the cache TTL are looked up in batches.
+func fetchImageInfo(client *mwclient.Client, wikiConfig setup.WikiConfig, titles []string) (map[string]*MapImage, error) {
+	ttl := wikiConfig.Cache.Ttl
+	if ttl == 0 {
+		ttl = defaultCacheTtl
+	}
+
+	images := map[string]*MapImage{}
+	var missing []string
+
+	imageInfoMutex.Lock()
+	for _, title := range titles {
+		if entry, ok := imageInfos[title]; ok && time.Since(entry.fetchedAt) < ttl {
+			images[title] = entry.image
+		} else if !slices.Contains(missing, title) {
+			missing = append(missing, title)
+		}
+	}
+	imageInfoMutex.Unlock()
+
+	for start := 0; start < len(missing); start += imageInfoBatchSize {
+		batch := missing[start:min(start+imageInfoBatchSize, len(missing))]
+		fetched, err := fetchImageInfoBatch(client, batch)
+		if err != nil {
+			return images, err
+		}
+
+		imageInfoMutex.Lock()
+		for _, title := range batch {
+			images[title] = fetched[title]
+			imageInfos[title] = &imageInfoEntry{image: fetched[title], fetchedAt: time.Now()}
+		}
+		imageInfoMutex.Unlock()
+	}
+
+	return images, nil
+}
+
+func fetchImageInfoBatch(client *mwclient.Client, titles []string) (map[string]*MapImage, error) {
+	parameters := params.Values{
+		"action":      "query",
+		"format":      "json",
+		"prop":        "imageinfo",
+		"titles":      strings.Join(titles, "|"),
+		"iiprop":      "size|url",
+		"iiurlwidth":  "320",
+		"iiurlheight": "240",
+	}
+
+	results, err := client.Get(parameters)
+	if err != nil {
+		return nil, upstreamError(err)
+	}
+
+	// The API answers with normalized titles; map them back to the ones
+	// that were requested.
+	requested := map[string]string{}
+	for _, title := range titles {
+		requested[title] = title
+	}
+	if normalized, err := results.GetObjectArray("query", "normalized"); err == nil {
+		for _, normalization := range normalized {
+			from, errFrom := normalization.GetString("from")
+			to, errTo := normalization.GetString("to")
+			if errFrom == nil && errTo == nil {
+				requested[to] = from
+			}
+		}
+	}
+
+	pages, err := results.GetObjectArray("query", "pages")
+	if err != nil {
+		return nil, err
+	}
+
+	images := map[string]*MapImage{}
+	for _, page := range pages {
+		title, err := page.GetString("title")
+		if err != nil {
+			return nil, err
+		}
+
+		imageInfo, err := page.GetObjectArray("imageinfo")
+		if err != nil || len(imageInfo) == 0 {
+			continue
+		}
+
+		url, err := imageInfo[0].GetString("url")
+		if err != nil {
+			return nil, err
+		}
+
+		image := &MapImage{Url: url}
+		if width, err := imageInfo[0].GetInt64("width"); err == nil {
+			image.Width = int(width)
+		}
+		if height, err := imageInfo[0].GetInt64("height"); err == nil {
+			image.Height = int(height)
+		}
+		if thumbUrl, err := imageInfo[0].GetString("thumburl"); err == nil {
+			image.ThumbUrl = thumbUrl
+		}
+		if thumbWidth, err := imageInfo[0].GetInt64("thumbwidth"); err == nil {
+			image.ThumbWidth = int(thumbWidth)
+		}
+		if thumbHeight, err := imageInfo[0].GetInt64("thumbheight"); err == nil {
+			image.ThumbHeight = int(thumbHeight)
+		}
+
+		if requestedTitle, ok := requested[title]; ok {
+			images[requestedTitle] = image
+		} else {
+			images[title] = image
+		}
+	}
+
+	return images, nil
+}
+
+// resolveMapImages sets the image of each location map. Maps whose file does
+// not exist are left without one.
+func resolveMapImages(client *mwclient.Client, wikiConfig setup.WikiConfig, locationMaps []*LocationMap) error {
+	var titles []string
+	for _, locationMap := range locationMaps {
+		if locationMap.Path != "" {
+			titles = append(titles, fileTitle(locationMap.Path))
+		}
+	}
+
+	images, err := fetchImageInfo(client, wikiConfig, titles)
+	if err != nil {
+		return err
+	}
+
+	for _, locationMap := range locationMaps {
+		if locationMap.Path != "" {
+			locationMap.Image = images[fileTitle(locationMap.Path)]
+		}
+	}
+
+	return nil
+}
+
+// GetMapCatalog lists the maps of every location of a game that has any,
+// ordered by location title, with their image metadata.
+func GetMapCatalog(gameCode string, wikiConfig setup.WikiConfig) (catalog *MapCatalog, err error) {
+	locations, _, err := CachedLocations(gameCode, wikiConfig)
+	if err != nil {
+		return catalog, err
+	}
+
+	catalog = &MapCatalog{
+		Game:      gameCode,
+		Locations: []*LocationMaps{},
+	}
+
+	// Cached locations are shared, so their maps are copied before images
+	// are set on them.
+	var allMaps []*LocationMap
+	for _, location := range locations {
+		if len(location.LocationMaps) == 0 {
+			continue
+		}
+
+		locationMaps := &LocationMaps{Title: location.Title}
+		for _, locationMap := range location.LocationMaps {
+			copied := *locationMap
+			locationMaps.LocationMaps = append(locationMaps.LocationMaps, &copied)
+		}
+		allMaps = append(allMaps, locationMaps.LocationMaps...)
+		catalog.Locations = append(catalog.Locations, locationMaps)
+	}
+
+	sort.Slice(catalog.Locations, func(i, j int) bool {
+		return catalog.Locations[i].Title < catalog.Locations[j].Title
+	})
+
+	client, err := createClient()
+	if err != nil {
+		return catalog, err
+	}
+
+	err = resolveMapImages(client, wikiConfig, allMaps)
+	return catalog, err
+}
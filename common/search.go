@@ -0,0 +1,276 @@
+package common
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ynoproject/wikiwrapper/setup"
+)
+
+const (
+	SearchFieldTitle        = "title"
+	SearchFieldOriginalName = "originalName"
+	SearchFieldBGM          = "bgm"
+	SearchFieldAuthor       = "author"
+)
+
+// searchFieldWeights ranks matches on a location's own names above matches
+// on the music and people associated with it.
+var searchFieldWeights = map[string]float64{
+	SearchFieldTitle:        1,
+	SearchFieldOriginalName: 0.9,
+	SearchFieldBGM:          0.7,
+	SearchFieldAuthor:       0.6,
+}
+
+const (
+	scoreExact     = 100.0
+	scorePrefix    = 80.0
+	scoreWordStart = 60.0
+	scoreSubstring = 40.0
+	scoreFuzzy     = 25.0
+)
+
+type SearchResult struct {
+	Title string  `json:"title"`
+	Score float64 `json:"score"`
+	Field string  `json:"field"`
+	Match string  `json:"match"`
+}
+
+type SearchResults struct {
+	Game    string          `json:"game"`
+	Query   string          `json:"query"`
+	Results []*SearchResult `json:"results"`
+}
+
+type searchEntry struct {
+	title string
+	field string
+	text  string
+	value string
+	words []string
+}
+
+// SearchIndex holds the normalized searchable text of every location of a
+// game.
+type SearchIndex struct {
+	entries []*searchEntry
+}
+
+var (
+	searchIndexMutex sync.Mutex
+	searchIndexes    = map[string]*searchIndexEntry{}
+)
+
+type searchIndexEntry struct {
+	index     *SearchIndex
+	builtFrom time.Time
+}
+
+// normalizeSearchText folds case and full-width letters, so "Ａ", "A" and
+// "a" all match, and collapses whitespace.
+func normalizeSearchText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if r >= '！' && r <= '～' {
+			r -= '！' - '!'
+		} else if r == '\u3000' {
+			r = ' '
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func NewSearchIndex(locations []*Location) *SearchIndex {
+	index := &SearchIndex{}
+	for _, location := range locations {
+		index.add(location.Title, SearchFieldTitle, location.Title)
+		index.add(location.Title, SearchFieldOriginalName, location.OriginalName)
+		for _, bgm := range location.BGMs {
+			index.add(location.Title, SearchFieldBGM, bgm.Title)
+		}
+		for _, author := range strings.Split(location.PrimaryAuthor, ", ") {
+			index.add(location.Title, SearchFieldAuthor, author)
+		}
+		for _, author := range location.ContributingAuthors {
+			index.add(location.Title, SearchFieldAuthor, author)
+		}
+	}
+	return index
+}
+
+func (i *SearchIndex) add(title string, field string, value string) {
+	text := normalizeSearchText(value)
+	if text == "" {
+		return
+	}
+
+	i.entries = append(i.entries, &searchEntry{
+		title: title,
+		field: field,
+		text:  text,
+		value: value,
+		words: strings.Fields(text),
+	})
+}
+
+// Search returns the locations matching a query, best matches first. Each
+// location appears once, with the field that matched it best. Exact matches
+// rank above prefixes, matches at the start of a word, substrings and
+// finally matches within a small edit distance of every query word.
+func (i *SearchIndex) Search(query string, limit int) []*SearchResult {
+	query = normalizeSearchText(query)
+	results := []*SearchResult{}
+	if query == "" {
+		return results
+	}
+	queryWords := strings.Fields(query)
+
+	best := map[string]*SearchResult{}
+	for _, entry := range i.entries {
+		score := matchScore(entry, query, queryWords) * searchFieldWeights[entry.field]
+		if score == 0 {
+			continue
+		}
+
+		if result, ok := best[entry.title]; !ok || score > result.Score {
+			best[entry.title] = &SearchResult{
+				Title: entry.title,
+				Score: score,
+				Field: entry.field,
+				Match: entry.value,
+			}
+		}
+	}
+
+	for _, result := range best {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(a, b int) bool {
+		if results[a].Score != results[b].Score {
+			return results[a].Score > results[b].Score
+		}
+		return results[a].Title < results[b].Title
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func matchScore(entry *searchEntry, query string, queryWords []string) float64 {
+	switch {
+	case entry.text == query:
+		return scoreExact
+	case strings.HasPrefix(entry.text, query):
+		return scorePrefix
+	}
+
+	wordStarts, fuzzy := true, true
+	distance := 0
+	for _, queryWord := range queryWords {
+		wordStart, wordDistance := false, -1
+		for _, word := range entry.words {
+			if strings.HasPrefix(word, queryWord) {
+				wordStart = true
+				break
+			}
+			if d := editDistance(word, queryWord); d <= maxEditDistance(queryWord) && (wordDistance < 0 || d < wordDistance) {
+				wordDistance = d
+			}
+		}
+
+		if !wordStart {
+			wordStarts = false
+			if wordDistance < 0 {
+				fuzzy = false
+			} else {
+				distance += wordDistance
+			}
+		}
+	}
+
+	switch {
+	case wordStarts:
+		return scoreWordStart
+	case strings.Contains(entry.text, query):
+		return scoreSubstring
+	case fuzzy:
+		return scoreFuzzy / float64(1+distance)
+	}
+	return 0
+}
+
+// maxEditDistance is the number of typos tolerated in a query word; short
+// words must be spelled exactly.
+func maxEditDistance(word string) int {
+	length := len([]rune(word))
+	switch {
+	case length < 4:
+		return 0
+	case length < 8:
+		return 1
+	}
+	return 2
+}
+
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+// GetSearchIndex returns the search index of a game, rebuilt whenever the
+// cached locations it is built from are refreshed.
+func GetSearchIndex(gameCode string, wikiConfig setup.WikiConfig) (*SearchIndex, error) {
+	locations, fetchedAt, err := cachedLocations(gameCode, wikiConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	searchIndexMutex.Lock()
+	defer searchIndexMutex.Unlock()
+
+	if entry, ok := searchIndexes[gameCode]; ok && entry.builtFrom.Equal(fetchedAt) {
+		return entry.index, nil
+	}
+
+	index := NewSearchIndex(locations.Locations)
+	searchIndexes[gameCode] = &searchIndexEntry{index: index, builtFrom: fetchedAt}
+	return index, nil
+}
+
+func Search(gameCode string, query string, limit int, wikiConfig setup.WikiConfig) (results *SearchResults, err error) {
+	index, err := GetSearchIndex(gameCode, wikiConfig)
+	if err != nil {
+		return results, err
+	}
+
+	return &SearchResults{
+		Game:    gameCode,
+		Query:   query,
+		Results: index.Search(query, limit),
+	}, nil
+}
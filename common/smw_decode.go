@@ -0,0 +1,307 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/antonholmquist/jason"
+)
+
+// DecodeError reports a field that could not be decoded from a printout.
+type DecodeError struct {
+	Field    string
+	Property string
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s (%s): %s", e.Field, e.Property, e.Err.Error())
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeErrors lists every field that could not be decoded from a record.
+// The fields it names are left at their zero value.
+type DecodeErrors []*DecodeError
+
+func (e DecodeErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+type smwTag struct {
+	key  string
+	page bool
+	join bool
+	item string
+}
+
+type smwDecoder struct {
+	property func(key string) string
+	errs     DecodeErrors
+}
+
+// DecodePrintouts fills the tagged fields of out, a pointer to a struct, from
+// the printouts of an askargs result. Every field is attempted; when some
+// fail the returned error is a DecodeErrors.
+//
+// A field tagged `smw:"key,options"` is filled from the printout whose
+// property is returned by the decoder's resolver for key, or from the
+// property named key when there is no resolver. The field type decides how
+// values are read: strings, bools and ints take the first value, slices take
+// all of them, and slices of structs are decoded as records whose own tagged
+// fields are read from each record's "item" values.
+//
+// Options:
+//
+//	page    values are pages; their title without namespace is used
+//	join    multiple values are joined with ", " into a string field
+//	item=k  values are records and only their subfield k is kept
+func DecodePrintouts(value *jason.Object, out interface{}, property func(key string) string) error {
+	printouts, err := value.GetObject("printouts")
+	if err != nil {
+		return err
+	}
+
+	target := reflect.ValueOf(out)
+	if target.Kind() != reflect.Pointer || target.Elem().Kind() != reflect.Struct {
+		return errors.New("decode target must be a pointer to a struct")
+	}
+
+	decoder := &smwDecoder{property: property}
+	decoder.decodeStruct(printouts, target.Elem(), "", false)
+	if len(decoder.errs) > 0 {
+		return decoder.errs
+	}
+	return nil
+}
+
+func parseSmwTag(tag string) smwTag {
+	parts := strings.Split(tag, ",")
+	parsed := smwTag{key: parts[0]}
+	for _, option := range parts[1:] {
+		switch {
+		case option == "page":
+			parsed.page = true
+		case option == "join":
+			parsed.join = true
+		case strings.HasPrefix(option, "item="):
+			parsed.item = strings.TrimPrefix(option, "item=")
+		}
+	}
+	return parsed
+}
+
+func (d *smwDecoder) resolve(key string) string {
+	if d.property == nil {
+		return key
+	}
+	if property := d.property(key); property != "" {
+		return property
+	}
+	return key
+}
+
+func (d *smwDecoder) fail(field string, property string, err error) {
+	d.errs = append(d.errs, &DecodeError{Field: field, Property: property, Err: err})
+}
+
+// values returns the values of a property. Values of a record subfield are
+// nested under "item".
+func values(object *jason.Object, property string, inRecord bool) ([]*jason.Value, error) {
+	if inRecord {
+		return object.GetValueArray(property, "item")
+	}
+	return object.GetValueArray(property)
+}
+
+func (d *smwDecoder) decodeStruct(object *jason.Object, target reflect.Value, prefix string, inRecord bool) {
+	targetType := target.Type()
+	for i := 0; i < targetType.NumField(); i++ {
+		field := targetType.Field(i)
+		tag, ok := field.Tag.Lookup("smw")
+		if !ok {
+			continue
+		}
+
+		smwTag := parseSmwTag(tag)
+		property := d.resolve(smwTag.key)
+		name := prefix + fieldName(field)
+
+		fieldValues, err := values(object, property, inRecord)
+		if err != nil {
+			d.fail(name, property, err)
+			continue
+		}
+
+		if smwTag.item != "" {
+			fieldValues, err = d.recordItems(fieldValues, d.resolve(smwTag.item))
+			if err != nil {
+				d.fail(name, property, err)
+				continue
+			}
+		}
+
+		if err := d.decodeField(fieldValues, target.Field(i), smwTag, name); err != nil {
+			d.fail(name, property, err)
+		}
+	}
+}
+
+// recordItems replaces each record by the values of one of its subfields.
+func (d *smwDecoder) recordItems(records []*jason.Value, subfield string) ([]*jason.Value, error) {
+	items := []*jason.Value{}
+	for _, record := range records {
+		object, err := record.Object()
+		if err != nil {
+			return nil, err
+		}
+
+		subfieldValues, err := values(object, subfield, true)
+		if err != nil {
+			return nil, err
+		}
+		if len(subfieldValues) > 0 {
+			items = append(items, subfieldValues[0])
+		}
+	}
+	return items, nil
+}
+
+func (d *smwDecoder) decodeField(fieldValues []*jason.Value, target reflect.Value, tag smwTag, name string) error {
+	switch target.Kind() {
+	case reflect.String:
+		strs, err := valueStrings(fieldValues, tag.page)
+		if err != nil {
+			return err
+		}
+		if tag.join {
+			target.SetString(strings.Join(strs, ", "))
+		} else if len(strs) > 0 {
+			target.SetString(strs[0])
+		}
+	case reflect.Bool:
+		strs, err := valueStrings(fieldValues, tag.page)
+		if err != nil {
+			return err
+		}
+		target.SetBool(len(strs) > 0 && (strs[0] == "t" || strs[0] == "true"))
+	case reflect.Int:
+		ints, err := valueInts(fieldValues)
+		if err != nil {
+			return err
+		}
+		if len(ints) > 0 {
+			target.SetInt(int64(ints[0]))
+		}
+	case reflect.Slice:
+		return d.decodeSlice(fieldValues, target, tag, name)
+	default:
+		return fmt.Errorf("unsupported field type %s", target.Type())
+	}
+	return nil
+}
+
+func (d *smwDecoder) decodeSlice(fieldValues []*jason.Value, target reflect.Value, tag smwTag, name string) error {
+	elemType := target.Type().Elem()
+	switch {
+	case elemType.Kind() == reflect.String:
+		strs, err := valueStrings(fieldValues, tag.page)
+		if err != nil {
+			return err
+		}
+		target.Set(reflect.ValueOf(strs))
+	case elemType.Kind() == reflect.Int:
+		ints, err := valueInts(fieldValues)
+		if err != nil {
+			return err
+		}
+		target.Set(reflect.ValueOf(ints))
+	case elemType.Kind() == reflect.Pointer && elemType.Elem().Kind() == reflect.Struct:
+		records := reflect.MakeSlice(target.Type(), 0, len(fieldValues))
+		for _, fieldValue := range fieldValues {
+			object, err := fieldValue.Object()
+			if err != nil {
+				return err
+			}
+
+			record := reflect.New(elemType.Elem())
+			d.decodeStruct(object, record.Elem(), name+".", true)
+			records = reflect.Append(records, record)
+		}
+		target.Set(records)
+	default:
+		return fmt.Errorf("unsupported field type %s", target.Type())
+	}
+	return nil
+}
+
+func valueStrings(fieldValues []*jason.Value, page bool) ([]string, error) {
+	strs := make([]string, 0, len(fieldValues))
+	for _, fieldValue := range fieldValues {
+		if page {
+			object, err := fieldValue.Object()
+			if err != nil {
+				return nil, err
+			}
+
+			fulltext, err := object.GetString("fulltext")
+			if err != nil {
+				return nil, err
+			}
+
+			strs = append(strs, pageTitle(fulltext))
+			continue
+		}
+
+		if str, err := fieldValue.String(); err == nil {
+			strs = append(strs, str)
+		} else if number, err := fieldValue.Number(); err == nil {
+			strs = append(strs, number.String())
+		} else {
+			return nil, err
+		}
+	}
+	return strs, nil
+}
+
+// valueInts reads integer values, skipping the ones that are not integers.
+func valueInts(fieldValues []*jason.Value) ([]int, error) {
+	ints := make([]int, 0, len(fieldValues))
+	strs, err := valueStrings(fieldValues, false)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, str := range strs {
+		if i, err := strconv.Atoi(str); err == nil {
+			ints = append(ints, i)
+		}
+	}
+	return ints, nil
+}
+
+func fieldName(field reflect.StructField) string {
+	if jsonTag, ok := field.Tag.Lookup("json"); ok {
+		if name := strings.Split(jsonTag, ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// pageTitle strips the namespace from a page name.
+func pageTitle(fulltext string) string {
+	if _, title, found := strings.Cut(fulltext, ":"); found {
+		return title
+	}
+	return fulltext
+}
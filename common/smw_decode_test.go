@@ -0,0 +1,219 @@
+package common
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/antonholmquist/jason"
+	"github.com/ynoproject/wikiwrapper/setup"
+)
+
+// loadResult reads the single result of an askargs response saved in
+// testdata.
+func loadResult(t *testing.T, name string) *jason.Object {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := jason.NewObjectFromBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := response.GetObjectArray("query", "results")
+	if err != nil || len(results) != 1 {
+		t.Fatalf("%s: expected a single result: %v", name, err)
+	}
+
+	for _, value := range results[0].Map() {
+		result, err := value.Object()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	t.Fatalf("%s: empty result", name)
+	return nil
+}
+
+func TestDecodePrintouts(t *testing.T) {
+	property := setup.Game{}.Property
+
+	tests := []struct {
+		name    string
+		fixture string
+		out     interface{}
+		want    interface{}
+	}{
+		{
+			name:    "location",
+			fixture: "askargs_location.json",
+			out:     &Location{},
+			want: &Location{
+				LocationImage:       "Fairy Garden.png",
+				BackgroundColor:     "#d4f5c4",
+				FontColor:           "darkgreen",
+				OriginalName:        "妖精の庭",
+				PrimaryAuthor:       "Kiku, Nuwa",
+				ContributingAuthors: []string{"Zenmaigahara"},
+				BGMs: []*BGM{
+					{Path: "Fairy Garden BGM.ogg", Title: "064"},
+					{Path: "Fairy Garden Pond.ogg", Title: "065", Label: "Pond"},
+				},
+				LocationMaps: []*LocationMap{
+					{Path: "Fairy Garden Map.png", Caption: "Map of the Fairy Garden"},
+				},
+				MapIds:          []int{1120, 1121},
+				VersionAdded:    "0.108",
+				VersionsUpdated: []string{"0.112b", "0.120 patch 3"},
+				VersionGaps:     []string{},
+			},
+		},
+		{
+			name:    "connection",
+			fixture: "askargs_connection.json",
+			out:     &Connection{},
+			want: &Connection{
+				Origin:           "Fairy Garden",
+				Destination:      "Flower Garden",
+				Attributes:       []string{"OneWay", "Chance"},
+				EffectsNeeded:    []string{"Fairy"},
+				SeasonAvailable:  "Spring and Summer",
+				ChancePercentage: "25",
+				IsRemoved:        true,
+			},
+		},
+		{
+			name:    "author",
+			fixture: "askargs_author.json",
+			out:     &Author{},
+			want:    &Author{Name: "Kiku", OriginalName: "きく"},
+		},
+		{
+			name:    "scalar takes the first value",
+			fixture: "askargs_location.json",
+			out: &struct {
+				PrimaryAuthor string `smw:"location.primaryAuthor"`
+				MapId         int    `smw:"location.mapIds,item=mapId.id"`
+			}{},
+			want: &struct {
+				PrimaryAuthor string `smw:"location.primaryAuthor"`
+				MapId         int    `smw:"location.mapIds,item=mapId.id"`
+			}{PrimaryAuthor: "Kiku", MapId: 1120},
+		},
+		{
+			name:    "property without resolver mapping",
+			fixture: "askargs_connection.json",
+			out: &struct {
+				Season string `smw:"Connection/Season available"`
+			}{},
+			want: &struct {
+				Season string `smw:"Connection/Season available"`
+			}{Season: "Spring and Summer"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := DecodePrintouts(loadResult(t, test.fixture), test.out, property)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(test.out, test.want) {
+				t.Errorf("got %+v, want %+v", test.out, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodePrintoutsErrors(t *testing.T) {
+	property := setup.Game{}.Property
+
+	type partial struct {
+		Image       string  `json:"locationImage" smw:"location.image"`
+		Missing     string  `json:"missing" smw:"Has no such property"`
+		Bgm         string  `json:"bgm" smw:"location.bgm,page"`
+		Version     float64 `json:"version" smw:"location.versionAdded"`
+		VersionGaps []string
+	}
+
+	tests := []struct {
+		name       string
+		fixture    string
+		out        interface{}
+		wantFields []string
+		want       interface{}
+	}{
+		{
+			name:       "failed fields are listed and the others decoded",
+			fixture:    "askargs_location.json",
+			out:        &partial{},
+			wantFields: []string{"missing", "bgm", "version"},
+			want:       &partial{Image: "Fairy Garden.png"},
+		},
+		{
+			name:    "record subfields are named after their record",
+			fixture: "askargs_location.json",
+			out: &struct {
+				BGMs []*struct {
+					Path int `json:"path" smw:"Has no such property"`
+				} `json:"bgms" smw:"location.bgm"`
+			}{},
+			wantFields: []string{"bgms.path", "bgms.path"},
+		},
+		{
+			name:    "page values must be pages",
+			fixture: "askargs_connection.json",
+			out: &struct {
+				Attributes []string `json:"attributes" smw:"connection.attribute,page"`
+			}{},
+			wantFields: []string{"attributes"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := DecodePrintouts(loadResult(t, test.fixture), test.out, property)
+
+			var decodeErrors DecodeErrors
+			if !errors.As(err, &decodeErrors) {
+				t.Fatalf("expected DecodeErrors, got %v", err)
+			}
+
+			fields := []string{}
+			for _, decodeError := range decodeErrors {
+				fields = append(fields, decodeError.Field)
+			}
+			if !reflect.DeepEqual(fields, test.wantFields) {
+				t.Errorf("failed fields %v, want %v", fields, test.wantFields)
+			}
+
+			if test.want != nil && !reflect.DeepEqual(test.out, test.want) {
+				t.Errorf("got %+v, want %+v", test.out, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodePrintoutsInvalidInput(t *testing.T) {
+	result := loadResult(t, "askargs_author.json")
+
+	var author Author
+	if err := DecodePrintouts(result, author, nil); err == nil {
+		t.Error("expected an error for a non-pointer target")
+	}
+
+	printouts, err := result.GetObject("printouts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DecodePrintouts(printouts, &author, nil); err == nil {
+		t.Error("expected an error for a result without printouts")
+	}
+}
@@ -1,7 +1,10 @@
 package common
 
 import (
-	"fmt"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/antonholmquist/jason"
 
@@ -9,13 +12,212 @@ import (
 	"cgt.name/pkg/go-mwclient/params"
 )
 
+// multiValueSeparator replaces "|" between the values of a multi-value API
+// parameter when one of the values contains a "|" itself.
+const multiValueSeparator = "\x1f"
+
+var smwValueEscaper = strings.NewReplacer(
+	"|", "&#124;",
+	"[[", "&#91;&#91;",
+	"]]", "&#93;&#93;",
+)
+
+const defaultSmwApiVersion = 3
+
+// SmwRequest is a Semantic MediaWiki query whose results can be fetched
+// starting at any offset.
+type SmwRequest interface {
+	Params(offset int) params.Values
+}
+
+// AskArgsQuery builds the parameters of a Semantic MediaWiki askargs request.
+type AskArgsQuery struct {
+	conditions []string
+	printouts  []string
+	sorts      []string
+	orders     []string
+	limit      int
+	offset     int
+	apiVersion int
+}
+
+// AskQuery is a raw #ask query run with action=ask, for queries askargs
+// cannot express such as property chains and inverse properties.
+type AskQuery struct {
+	query      string
+	apiVersion int
+}
+
 type SmwQuery struct {
 	w      *mwclient.Client
-	params params.Values
+	query  SmwRequest
+	offset int
 	resp   *jason.Object
 	err    error
 }
 
+func NewAskArgsQuery() *AskArgsQuery {
+	return &AskArgsQuery{apiVersion: defaultSmwApiVersion}
+}
+
+// NewAskQuery wraps a query written in #ask syntax, such as
+// "[[Category:Locations]]|?Has BGM|limit=50".
+func NewAskQuery(query string) *AskQuery {
+	return &AskQuery{query: query, apiVersion: defaultSmwApiVersion}
+}
+
+// ApiVersion selects the response format of the query. Version 2 is the
+// only one supported by older Semantic MediaWiki installations; 0 keeps
+// the default.
+func (q *AskQuery) ApiVersion(version int) *AskQuery {
+	if version != 0 {
+		q.apiVersion = version
+	}
+	return q
+}
+
+func (q *AskQuery) Params(offset int) params.Values {
+	query := q.query
+	if offset > 0 {
+		query += "|offset=" + strconv.Itoa(offset)
+	}
+
+	return params.Values{
+		"action":      "ask",
+		"query":       query,
+		"format":      "json",
+		"api_version": strconv.Itoa(q.apiVersion),
+	}
+}
+
+// EscapeSmwValue escapes the characters of a page title or property value
+// that would otherwise end a condition or separate parameters. Titles are
+// stored unescaped since MediaWiki decodes character references in them.
+func EscapeSmwValue(value string) string {
+	return smwValueEscaper.Replace(value)
+}
+
+// Condition adds a raw condition, written without its enclosing brackets.
+func (q *AskArgsQuery) Condition(condition string) *AskArgsQuery {
+	q.conditions = append(q.conditions, condition)
+	return q
+}
+
+// Page restricts the results to a single page.
+func (q *AskArgsQuery) Page(title string) *AskArgsQuery {
+	return q.Condition(EscapeSmwValue(title))
+}
+
+// Category restricts the results to the pages of a category, given without
+// its namespace.
+func (q *AskArgsQuery) Category(category string) *AskArgsQuery {
+	return q.Condition("Category:" + EscapeSmwValue(category))
+}
+
+// Namespace restricts the results to the pages of a namespace.
+func (q *AskArgsQuery) Namespace(namespace string) *AskArgsQuery {
+	return q.Condition(EscapeSmwValue(namespace) + ":+")
+}
+
+// Property restricts the results to pages with the given property value.
+func (q *AskArgsQuery) Property(property string, value string) *AskArgsQuery {
+	return q.Condition(property + "::" + EscapeSmwValue(value))
+}
+
+func (q *AskArgsQuery) Printout(properties ...string) *AskArgsQuery {
+	q.printouts = append(q.printouts, properties...)
+	return q
+}
+
+// Sort orders the results by a property, ascending with "asc" or descending
+// with "desc".
+func (q *AskArgsQuery) Sort(property string, order string) *AskArgsQuery {
+	q.sorts = append(q.sorts, property)
+	q.orders = append(q.orders, order)
+	return q
+}
+
+func (q *AskArgsQuery) Limit(limit int) *AskArgsQuery {
+	q.limit = limit
+	return q
+}
+
+func (q *AskArgsQuery) Offset(offset int) *AskArgsQuery {
+	q.offset = offset
+	return q
+}
+
+// ApiVersion selects the response format of the query, as for AskQuery.
+func (q *AskArgsQuery) ApiVersion(version int) *AskArgsQuery {
+	if version != 0 {
+		q.apiVersion = version
+	}
+	return q
+}
+
+// Ask renders the query in #ask syntax.
+func (q *AskArgsQuery) Ask() *AskQuery {
+	var b strings.Builder
+	for _, condition := range q.conditions {
+		b.WriteString("[[" + condition + "]]")
+	}
+	for _, printout := range q.printouts {
+		b.WriteString("|?" + printout)
+	}
+	for _, parameter := range q.parameters(0) {
+		b.WriteString("|" + parameter)
+	}
+
+	return &AskQuery{query: b.String(), apiVersion: q.apiVersion}
+}
+
+func joinMultiValue(values []string) string {
+	for _, value := range values {
+		if strings.Contains(value, "|") {
+			return multiValueSeparator + strings.Join(values, multiValueSeparator)
+		}
+	}
+	return strings.Join(values, "|")
+}
+
+func (q *AskArgsQuery) parameters(offset int) []string {
+	var parameters []string
+	if len(q.sorts) > 0 {
+		parameters = append(parameters, "sort="+strings.Join(q.sorts, ","), "order="+strings.Join(q.orders, ","))
+	}
+	if q.limit > 0 {
+		parameters = append(parameters, "limit="+strconv.Itoa(q.limit))
+	}
+	if offset > 0 {
+		parameters = append(parameters, "offset="+strconv.Itoa(offset))
+	}
+	return parameters
+}
+
+// Params returns the API parameters of the query. A non-zero offset takes
+// precedence over the one set on the query.
+func (q *AskArgsQuery) Params(offset int) params.Values {
+	if offset == 0 {
+		offset = q.offset
+	}
+	parameters := q.parameters(offset)
+
+	p := params.Values{
+		"action":      "askargs",
+		"conditions":  joinMultiValue(q.conditions),
+		"format":      "json",
+		"api_version": strconv.Itoa(q.apiVersion),
+	}
+	if len(q.printouts) > 0 {
+		p.Set("printouts", joinMultiValue(q.printouts))
+	}
+	if len(parameters) > 0 {
+		p.Set("parameters", joinMultiValue(parameters))
+	}
+
+	return p
+}
+
 // Err returns the first error encountered by the Next method.
 func (q *SmwQuery) Err() error {
 	return q.err
@@ -26,34 +228,78 @@ func (q *SmwQuery) Resp() *jason.Object {
 	return q.resp
 }
 
-func NewSmwQuery(w *mwclient.Client, p params.Values) *SmwQuery {
-	p.Set("action", "askargs")
-
+func NewSmwQuery(w *mwclient.Client, query SmwRequest) *SmwQuery {
 	return &SmwQuery{
-		w:      w,
-		params: p,
-		resp:   nil,
-		err:    nil,
+		w:     w,
+		query: query,
+		resp:  nil,
+		err:   nil,
 	}
 }
 
 func (q *SmwQuery) Next() (done bool) {
 	if q.resp == nil {
 		// first call to Next
-		q.resp, q.err = q.w.Get(q.params)
+		q.resp, q.err = q.w.Get(q.query.Params(q.offset))
+		q.err = upstreamError(q.err)
 		return q.err == nil
 	}
 
-	cont, err := q.resp.GetNumber("query-continue-offset")
+	cont, err := q.resp.GetInt64("query-continue-offset")
 	if err != nil {
 		return false
 	}
 
-	offset := fmt.Sprintf("|offset=%s", cont)
-	currentParams := q.params.Get("parameters")
-	q.params.Set("parameters", currentParams+offset)
-
-	q.resp, q.err = q.w.Get(q.params)
-	q.params.Set("parameters", currentParams)
+	q.offset = int(cont)
+	q.resp, q.err = q.w.Get(q.query.Params(q.offset))
+	q.err = upstreamError(q.err)
 	return q.err == nil
 }
+
+// Results returns the results of the API response retrieved by the Next
+// method, each as an object keyed by page name. api_version 3 returns a list
+// of single-page objects, while api_version 2 returns a single object keyed
+// by page name (or an empty list when there are no results); since the
+// latter does not keep the order of the results, its pages are split into
+// single-page objects ordered by page name.
+func (q *SmwQuery) Results() ([]*jason.Object, error) {
+	results, err := q.resp.GetValue("query", "results")
+	if err != nil {
+		return nil, err
+	}
+
+	if object, err := results.Object(); err == nil {
+		return pageResults(object)
+	}
+
+	return q.resp.GetObjectArray("query", "results")
+}
+
+func pageResults(results *jason.Object) ([]*jason.Object, error) {
+	pages := results.Map()
+	names := make([]string, 0, len(pages))
+	for name := range pages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	objects := make([]*jason.Object, 0, len(names))
+	for _, name := range names {
+		value, err := pages[name].Marshal()
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := json.Marshal(map[string]json.RawMessage{name: value})
+		if err != nil {
+			return nil, err
+		}
+
+		object, err := jason.NewObjectFromBytes(data)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
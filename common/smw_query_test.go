@@ -0,0 +1,135 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"cgt.name/pkg/go-mwclient/params"
+	"github.com/antonholmquist/jason"
+)
+
+func TestAskQueryParams(t *testing.T) {
+	const inverse = "[[-Has subobject::Yume 2kki:Authors]]|?Author/Name|sort=Author/Name|order=asc"
+
+	tests := []struct {
+		name   string
+		query  *AskQuery
+		offset int
+		want   params.Values
+	}{
+		{
+			name:  "first page",
+			query: NewAskQuery(inverse),
+			want: params.Values{
+				"action":      "ask",
+				"query":       inverse,
+				"format":      "json",
+				"api_version": "3",
+			},
+		},
+		{
+			name:   "offset is appended to the query",
+			query:  NewAskQuery(inverse),
+			offset: 500,
+			want: params.Values{
+				"action":      "ask",
+				"query":       inverse + "|offset=500",
+				"format":      "json",
+				"api_version": "3",
+			},
+		},
+		{
+			name:  "api version",
+			query: NewAskQuery("[[Category:Locations]]|?Has BGM.Has media path").ApiVersion(2),
+			want: params.Values{
+				"action":      "ask",
+				"query":       "[[Category:Locations]]|?Has BGM.Has media path",
+				"format":      "json",
+				"api_version": "2",
+			},
+		},
+		{
+			name:  "zero api version keeps the default",
+			query: NewAskQuery("[[Category:Locations]]").ApiVersion(0),
+			want: params.Values{
+				"action":      "ask",
+				"query":       "[[Category:Locations]]",
+				"format":      "json",
+				"api_version": "3",
+			},
+		},
+		{
+			name: "rendered from askargs",
+			query: NewAskArgsQuery().
+				Category("Locations").
+				Property("-Has subobject", "Yume 2kki:Authors").
+				Printout("Author/Name").
+				Sort("Author/Name", "asc").
+				Limit(50).
+				Ask(),
+			offset: 50,
+			want: params.Values{
+				"action":      "ask",
+				"query":       "[[Category:Locations]][[-Has subobject::Yume 2kki:Authors]]|?Author/Name|sort=Author/Name|order=asc|limit=50|offset=50",
+				"format":      "json",
+				"api_version": "3",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.query.Params(test.offset); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSmwQueryResults(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     []string
+	}{
+		{
+			name:     "api version 3 keeps the order of the query",
+			response: `{"query": {"results": [{"Yume 2kki:Zebra Sea": {}}, {"Yume 2kki:Apple Field": {}}]}}`,
+			want:     []string{"Yume 2kki:Zebra Sea", "Yume 2kki:Apple Field"},
+		},
+		{
+			name:     "api version 2 is ordered by page",
+			response: `{"query": {"results": {"Yume 2kki:Zebra Sea": {}, "Yume 2kki:Apple Field": {}, "Yume 2kki:Mall": {}}}}`,
+			want:     []string{"Yume 2kki:Apple Field", "Yume 2kki:Mall", "Yume 2kki:Zebra Sea"},
+		},
+		{
+			name:     "api version 2 without results",
+			response: `{"query": {"results": []}}`,
+			want:     []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := jason.NewObjectFromBytes([]byte(test.response))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			results, err := (&SmwQuery{resp: resp}).Results()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pages := []string{}
+			for _, result := range results {
+				for page := range result.Map() {
+					pages = append(pages, page)
+				}
+			}
+			if !reflect.DeepEqual(pages, test.want) {
+				t.Errorf("got %v, want %v", pages, test.want)
+			}
+		})
+	}
+}
@@ -4,20 +4,20 @@ import "encoding/json"
 
 type Location struct {
 	Title               string         `json:"title"`
-	LocationImage       string         `json:"locationImage"`
+	LocationImage       string         `json:"locationImage" smw:"location.image"`
 	Game                string         `json:"game"`
-	BackgroundColor     string         `json:"backgroundColor"`
-	FontColor           string         `json:"fontColor"`
-	OriginalName        string         `json:"originalName,omitempty"`
-	BGMs                []*BGM         `json:"bgms"`
-	LocationMaps        []*LocationMap `json:"locationMaps"`
-	PrimaryAuthor       string         `json:"primaryAuthor,omitempty"`
-	ContributingAuthors []string       `json:"contributingAuthors,omitempty"`
-	VersionAdded        string         `json:"versionAdded"`
-	VersionsUpdated     []string       `json:"versionsUpdated"`
-	VersionRemoved      string         `json:"versionRemoved,omitempty"`
-	VersionGaps         []string       `json:"versionGaps"`
-	MapIds              []int          `json:"mapIds"`
+	BackgroundColor     string         `json:"backgroundColor" smw:"location.backgroundColor"`
+	FontColor           string         `json:"fontColor" smw:"location.fontColor"`
+	OriginalName        string         `json:"originalName,omitempty" smw:"location.originalName"`
+	BGMs                []*BGM         `json:"bgms" smw:"location.bgm"`
+	LocationMaps        []*LocationMap `json:"locationMaps" smw:"location.map"`
+	PrimaryAuthor       string         `json:"primaryAuthor,omitempty" smw:"location.primaryAuthor,join"`
+	ContributingAuthors []string       `json:"contributingAuthors,omitempty" smw:"location.contributingAuthor"`
+	VersionAdded        string         `json:"versionAdded" smw:"location.versionAdded"`
+	VersionsUpdated     []string       `json:"versionsUpdated" smw:"location.versionsUpdated"`
+	VersionRemoved      string         `json:"versionRemoved,omitempty" smw:"location.versionRemoved"`
+	VersionGaps         []string       `json:"versionGaps" smw:"location.versionGaps"`
+	MapIds              []int          `json:"mapIds" smw:"location.mapIds,item=mapId.id"`
 	Protags             []string       `json:"protags,omitempty"`
 }
 
@@ -26,48 +26,99 @@ type Locations struct {
 	Game        string      `json:"game"`
 	Protags     []string    `json:"protags,omitempty"`
 	ContinueKey string      `json:"continueKey,omitempty"`
+	Warnings    []*Warning  `json:"warnings,omitempty"`
+}
+
+// Warning describes a record that could only be partially parsed, or was
+// skipped, when a response is requested in lenient mode.
+type Warning struct {
+	Page    string `json:"page"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+	Skipped bool   `json:"skipped,omitempty"`
 }
 
 type BGM struct {
-	Path  string `json:"path"`
-	Title string `json:"title"`
-	Label string `json:"label,omitempty"`
+	Path  string `json:"path" smw:"bgm.path"`
+	Title string `json:"title" smw:"bgm.title"`
+	Label string `json:"label,omitempty" smw:"bgm.label"`
 }
 
 type LocationMap struct {
-	Path    string `json:"path"`
-	Caption string `json:"caption"`
+	Path    string    `json:"path" smw:"locationMap.path"`
+	Caption string    `json:"caption" smw:"locationMap.caption"`
+	Image   *MapImage `json:"image,omitempty"`
+}
+
+// MapImage is the file a location map is drawn from, as reported by the
+// wiki's imageinfo API.
+type MapImage struct {
+	Url         string `json:"url"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	ThumbUrl    string `json:"thumbUrl,omitempty"`
+	ThumbWidth  int    `json:"thumbWidth,omitempty"`
+	ThumbHeight int    `json:"thumbHeight,omitempty"`
 }
 
 type Connection struct {
+	Game              string   `json:"game"`
+	Origin            string   `json:"origin" smw:"connection.origin,page"`
+	Destination       string   `json:"destination" smw:"connection.destination,page"`
+	Attributes        []string `json:"attributes" smw:"connection.attribute"`
+	UnlockConditions  string   `json:"unlockCondition,omitempty" smw:"connection.unlockConditions"`
+	EffectsNeeded     []string `json:"effectsNeeded,omitempty" smw:"connection.effectsNeeded"`
+	SeasonAvailable   string   `json:"seasonAvailable,omitempty" smw:"connection.seasonAvailable"`
+	ChancePercentage  string   `json:"chancePercentage,omitempty" smw:"connection.chancePercentage"`
+	ChanceDescription string   `json:"chanceDescription,omitempty" smw:"connection.chanceDescription"`
+	IsRemoved         bool     `json:"isRemoved,omitempty" smw:"connection.isRemoved"`
+	Protags           []string `json:"protags,omitempty"`
+}
+
+type Connections struct {
+	Connections []*Connection `json:"connections"`
+	Game        string        `json:"game"`
+	Protags     []string      `json:"protags,omitempty"`
+	ContinueKey string        `json:"continueKey,omitempty"`
+	Warnings    []*Warning    `json:"warnings,omitempty"`
+}
+
+// TypedConnection is a connection with its attributes, season and chance
+// normalized. It is returned by version 2 of the connections response.
+type TypedConnection struct {
 	Game              string   `json:"game"`
 	Origin            string   `json:"origin"`
 	Destination       string   `json:"destination"`
 	Attributes        []string `json:"attributes"`
+	OtherAttributes   []string `json:"otherAttributes,omitempty"`
 	UnlockConditions  string   `json:"unlockCondition,omitempty"`
 	EffectsNeeded     []string `json:"effectsNeeded,omitempty"`
-	SeasonAvailable   string   `json:"seasonAvailable,omitempty"`
-	ChancePercentage  string   `json:"chancePercentage,omitempty"`
+	Seasons           []Season `json:"seasons,omitempty"`
+	Chance            *float64 `json:"chance,omitempty"`
 	ChanceDescription string   `json:"chanceDescription,omitempty"`
 	IsRemoved         bool     `json:"isRemoved,omitempty"`
+	Protags           []string `json:"protags,omitempty"`
 }
 
-type Connections struct {
-	Connections []*Connection `json:"connections"`
-	Game        string        `json:"game"`
-	ContinueKey string        `json:"continueKey,omitempty"`
+type TypedConnections struct {
+	Version     int                `json:"version"`
+	Connections []*TypedConnection `json:"connections"`
+	Game        string             `json:"game"`
+	Protags     []string           `json:"protags,omitempty"`
+	ContinueKey string             `json:"continueKey,omitempty"`
+	Warnings    []*Warning         `json:"warnings,omitempty"`
 }
 
 type Author struct {
-	Name         string `json:"name"`
-	OriginalName string `json:"originalName,omitempty"`
+	Name         string `json:"name" smw:"author.name"`
+	OriginalName string `json:"originalName,omitempty" smw:"author.originalName,item=author.originalNameText"`
 }
 
 type VendingMachine struct {
 	Game     string   `json:"game"`
-	Path     string   `json:"path"`
-	MapId    string   `json:"mapId"`
-	EventIds []string `json:"eventIds"`
+	Path     string   `json:"path" smw:"vendingMachine.path"`
+	MapId    string   `json:"mapId" smw:"vendingMachine.mapId"`
+	EventIds []string `json:"eventIds" smw:"vendingMachine.eventId"`
 }
 
 type Effect struct {
@@ -0,0 +1,192 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	versionPattern      = regexp.MustCompile(`(?i)^v?(\d+(?:\.\d+)*)([a-z]*)(?:\s*\(?\s*patch\s*(\d+)\s*\)?)?$`)
+	versionRangePattern = regexp.MustCompile(`(?i)\s*(?:–|—|\bto\b|-)\s*`)
+)
+
+// Version is a game version as written on the wiki, such as "0.123",
+// "0.123g" or "0.123g patch 4".
+type Version struct {
+	Numbers []int
+	Letters string
+	Patch   int
+	raw     string
+}
+
+// ParseVersion reads a game version. Numbers are compared numerically, so
+// "0.99" comes before "0.100", then letter suffixes ("0.123" < "0.123a" <
+// "0.123b"), then patches.
+func ParseVersion(value string) (Version, error) {
+	value = strings.TrimSpace(value)
+	match := versionPattern.FindStringSubmatch(value)
+	if match == nil {
+		return Version{}, fmt.Errorf("%q is not a game version", value)
+	}
+
+	version := Version{
+		Letters: strings.ToLower(match[2]),
+		raw:     value,
+	}
+
+	for _, part := range strings.Split(match[1], ".") {
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			return Version{}, fmt.Errorf("%q is not a game version", value)
+		}
+		version.Numbers = append(version.Numbers, number)
+	}
+
+	if match[3] != "" {
+		patch, err := strconv.Atoi(match[3])
+		if err != nil {
+			return Version{}, fmt.Errorf("%q is not a game version", value)
+		}
+		version.Patch = patch
+	}
+
+	return version, nil
+}
+
+func (v Version) String() string {
+	return v.raw
+}
+
+// Compare returns a negative number when v is older than other, a positive
+// one when it is newer and 0 when both are the same version.
+func (v Version) Compare(other Version) int {
+	for i := 0; i < max(len(v.Numbers), len(other.Numbers)); i++ {
+		var a, b int
+		if i < len(v.Numbers) {
+			a = v.Numbers[i]
+		}
+		if i < len(other.Numbers) {
+			b = other.Numbers[i]
+		}
+		if a != b {
+			return a - b
+		}
+	}
+
+	// Longer suffixes come after shorter ones, as in "z" < "aa".
+	if len(v.Letters) != len(other.Letters) {
+		return len(v.Letters) - len(other.Letters)
+	}
+	if c := strings.Compare(v.Letters, other.Letters); c != 0 {
+		return c
+	}
+
+	return v.Patch - other.Patch
+}
+
+// VersionRange is an inclusive range of versions. A single version is a
+// range from and to itself.
+type VersionRange struct {
+	From Version
+	To   Version
+}
+
+// ParseVersionRange reads a version gap such as "0.100-0.105",
+// "0.100 to 0.105" or "0.110".
+func ParseVersionRange(value string) (VersionRange, error) {
+	bounds := versionRangePattern.Split(strings.TrimSpace(value), 2)
+
+	from, err := ParseVersion(bounds[0])
+	if err != nil {
+		return VersionRange{}, err
+	}
+
+	to := from
+	if len(bounds) == 2 {
+		to, err = ParseVersion(bounds[1])
+		if err != nil {
+			return VersionRange{}, err
+		}
+	}
+
+	if from.Compare(to) > 0 {
+		return VersionRange{}, fmt.Errorf("version range %q ends before it starts", value)
+	}
+
+	return VersionRange{From: from, To: to}, nil
+}
+
+func (r VersionRange) Contains(version Version) bool {
+	return r.From.Compare(version) <= 0 && version.Compare(r.To) <= 0
+}
+
+// PresentIn reports whether a location was in the game in a version: added
+// in or before it, not yet removed and not in one of its version gaps.
+// Versions that cannot be parsed are ignored, except VersionAdded, without
+// which the location is not considered present.
+func (l *Location) PresentIn(version Version) bool {
+	added, err := ParseVersion(l.VersionAdded)
+	if err != nil || added.Compare(version) > 0 {
+		return false
+	}
+
+	if removed, err := ParseVersion(l.VersionRemoved); err == nil && removed.Compare(version) <= 0 {
+		return false
+	}
+
+	for _, gap := range l.VersionGaps {
+		if versionRange, err := ParseVersionRange(gap); err == nil && versionRange.Contains(version) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compareVersions orders version strings, using their parsed versions when
+// both can be parsed. Otherwise runs of digits are compared by their numeric
+// value so "0.99" still comes before "0.100".
+func compareVersions(a string, b string) int {
+	versionA, errA := ParseVersion(a)
+	versionB, errB := ParseVersion(b)
+	if errA == nil && errB == nil {
+		return versionA.Compare(versionB)
+	}
+
+	for a != "" && b != "" {
+		var partA, partB string
+		partA, a = versionPart(a)
+		partB, b = versionPart(b)
+
+		numberA, errA := strconv.Atoi(partA)
+		numberB, errB := strconv.Atoi(partB)
+		if errA == nil && errB == nil {
+			if numberA != numberB {
+				return numberA - numberB
+			}
+			continue
+		}
+
+		if partA != partB {
+			return strings.Compare(partA, partB)
+		}
+	}
+
+	return len(a) - len(b)
+}
+
+// versionPart splits the leading run of digits, or the leading character, off
+// a version string.
+func versionPart(version string) (string, string) {
+	end := strings.IndexFunc(version, func(r rune) bool { return !unicode.IsDigit(r) })
+	switch end {
+	case -1:
+		return version, ""
+	case 0:
+		return version[:1], version[1:]
+	}
+	return version[:end], version[end:]
+}
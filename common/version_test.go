@@ -0,0 +1,158 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+)
+
+func mustParseVersion(t *testing.T, value string) Version {
+	t.Helper()
+
+	version, err := ParseVersion(value)
+	if err != nil {
+		t.Fatalf("ParseVersion(%q): %v", value, err)
+	}
+	return version
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		value   string
+		numbers []int
+		letters string
+		patch   int
+	}{
+		{"0.123", []int{0, 123}, "", 0},
+		{" 0.123g ", []int{0, 123}, "g", 0},
+		{"v0.123G", []int{0, 123}, "g", 0},
+		{"0.123g patch 4", []int{0, 123}, "g", 4},
+		{"0.123g (patch 4)", []int{0, 123}, "g", 4},
+		{"0.123 Patch 12", []int{0, 123}, "", 12},
+		{"1.0.2", []int{1, 0, 2}, "", 0},
+		{"7", []int{7}, "", 0},
+	}
+
+	for _, test := range tests {
+		version, err := ParseVersion(test.value)
+		if err != nil {
+			t.Errorf("ParseVersion(%q): %v", test.value, err)
+			continue
+		}
+		if !reflect.DeepEqual(version.Numbers, test.numbers) || version.Letters != test.letters || version.Patch != test.patch {
+			t.Errorf("ParseVersion(%q) = %v %q patch %d, want %v %q patch %d", test.value, version.Numbers, version.Letters, version.Patch, test.numbers, test.letters, test.patch)
+		}
+	}
+
+	for _, value := range []string{"", "latest", "0.", ".123", "0.123-", "0.123 patch", "0.12.x", "0.123g patch four"} {
+		if _, err := ParseVersion(value); err == nil {
+			t.Errorf("ParseVersion(%q): expected an error", value)
+		}
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"0.99", "0.100", -1},
+		{"0.100", "0.99", 1},
+		{"0.123", "0.123a", -1},
+		{"0.123a", "0.123b", -1},
+		{"0.123z", "0.123aa", -1},
+		{"0.123A", "0.123a", 0},
+		{"0.123", "0.123.0", 0},
+		{"0.123.1", "0.123", 1},
+		{"0.123g", "0.123g patch 1", -1},
+		{"0.123g patch 2", "0.123g patch 10", -1},
+		{"0.123g patch 4", "0.123h", -1},
+		{"v1.0", "1.0", 0},
+	}
+
+	for _, test := range tests {
+		a, b := mustParseVersion(t, test.a), mustParseVersion(t, test.b)
+		if got := sign(a.Compare(b)); got != test.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+		if got := sign(b.Compare(a)); got != -test.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", test.b, test.a, got, -test.want)
+		}
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+func TestParseVersionRange(t *testing.T) {
+	tests := []struct {
+		value    string
+		from, to string
+	}{
+		{"0.110", "0.110", "0.110"},
+		{"0.100-0.105", "0.100", "0.105"},
+		{"0.100 - 0.105", "0.100", "0.105"},
+		{"0.100–0.105", "0.100", "0.105"},
+		{"0.100 to 0.105b", "0.100", "0.105b"},
+		{"0.100g patch 2 - 0.101", "0.100g patch 2", "0.101"},
+	}
+
+	for _, test := range tests {
+		versionRange, err := ParseVersionRange(test.value)
+		if err != nil {
+			t.Errorf("ParseVersionRange(%q): %v", test.value, err)
+			continue
+		}
+		if versionRange.From.String() != test.from || versionRange.To.String() != test.to {
+			t.Errorf("ParseVersionRange(%q) = %q to %q, want %q to %q", test.value, versionRange.From, versionRange.To, test.from, test.to)
+		}
+	}
+
+	for _, value := range []string{"", "0.105-0.100", "0.100-", "soon-0.105"} {
+		if _, err := ParseVersionRange(value); err == nil {
+			t.Errorf("ParseVersionRange(%q): expected an error", value)
+		}
+	}
+}
+
+func TestLocationPresentIn(t *testing.T) {
+	location := &Location{
+		VersionAdded:   "0.100",
+		VersionRemoved: "0.130",
+		VersionGaps:    []string{"0.110-0.112", "0.120b", "unknown"},
+	}
+
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{"0.99", false},
+		{"0.100", true},
+		{"0.109g", true},
+		{"0.110", false},
+		{"0.112", false},
+		{"0.112a", true},
+		{"0.113", true},
+		{"0.120a", true},
+		{"0.120b", false},
+		{"0.129", true},
+		{"0.130", false},
+		{"0.131", false},
+	}
+
+	for _, test := range tests {
+		if got := location.PresentIn(mustParseVersion(t, test.version)); got != test.want {
+			t.Errorf("PresentIn(%q) = %t, want %t", test.version, got, test.want)
+		}
+	}
+
+	if (&Location{}).PresentIn(mustParseVersion(t, "0.100")) {
+		t.Error("a location without a version added is not present")
+	}
+}
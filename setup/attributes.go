@@ -0,0 +1,86 @@
+package setup
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	AttributeOneWay         = "one-way"
+	AttributeNoReturn       = "no-return"
+	AttributeNoEntry        = "no-entry"
+	AttributeDeadEnd        = "dead-end"
+	AttributeRequiresEvent  = "requires-event"
+	AttributeRequiresEffect = "requires-effect"
+	AttributeLocked         = "locked"
+	AttributePeriodic       = "periodic"
+	AttributeChance         = "chance"
+	AttributeShortcut       = "shortcut"
+	AttributeSeasonal       = "seasonal"
+	AttributeInaccessible   = "inaccessible"
+)
+
+var connectionAttributes = map[string]bool{
+	AttributeOneWay:         true,
+	AttributeNoReturn:       true,
+	AttributeNoEntry:        true,
+	AttributeDeadEnd:        true,
+	AttributeRequiresEvent:  true,
+	AttributeRequiresEffect: true,
+	AttributeLocked:         true,
+	AttributePeriodic:       true,
+	AttributeChance:         true,
+	AttributeShortcut:       true,
+	AttributeSeasonal:       true,
+	AttributeInaccessible:   true,
+}
+
+// defaultConnectionAttributes maps the connection attribute values used on
+// yume.wiki to their normalized attribute. Keys are lowercase; the config can
+// add values or remap them.
+var defaultConnectionAttributes = map[string]string{
+	"oneway":          AttributeOneWay,
+	"one-way":         AttributeOneWay,
+	"noreturn":        AttributeNoReturn,
+	"no return":       AttributeNoReturn,
+	"noentry":         AttributeNoEntry,
+	"no entry":        AttributeNoEntry,
+	"deadend":         AttributeDeadEnd,
+	"dead end":        AttributeDeadEnd,
+	"event":           AttributeRequiresEvent,
+	"effect":          AttributeRequiresEffect,
+	"locked":          AttributeLocked,
+	"lockedcondition": AttributeLocked,
+	"periodic":        AttributePeriodic,
+	"chance":          AttributeChance,
+	"shortcut":        AttributeShortcut,
+	"season":          AttributeSeasonal,
+	"inaccessible":    AttributeInaccessible,
+}
+
+// ConnectionAttribute returns the normalized attribute of a connection
+// attribute value read from the wiki.
+func (c WikiConfig) ConnectionAttribute(value string) (string, bool) {
+	key := strings.ToLower(strings.TrimSpace(value))
+	if attribute, ok := c.ConnectionAttributes[key]; ok {
+		return attribute, true
+	}
+
+	attribute, ok := defaultConnectionAttributes[key]
+	return attribute, ok
+}
+
+// validateConnectionAttributes checks the mapping table of the config and
+// lowercases its keys so lookups are case-insensitive.
+func validateConnectionAttributes(config *WikiConfig) error {
+	attributes := map[string]string{}
+	for value, attribute := range config.ConnectionAttributes {
+		if !connectionAttributes[attribute] {
+			return fmt.Errorf("connection attribute %q: unknown attribute %q", value, attribute)
+		}
+		attributes[strings.ToLower(strings.TrimSpace(value))] = attribute
+	}
+	config.ConnectionAttributes = attributes
+
+	return nil
+}
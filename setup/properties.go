@@ -0,0 +1,126 @@
+package setup
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	PropertyLocationImage              = "location.image"
+	PropertyLocationBackgroundColor    = "location.backgroundColor"
+	PropertyLocationFontColor          = "location.fontColor"
+	PropertyLocationPrimaryAuthor      = "location.primaryAuthor"
+	PropertyLocationContributingAuthor = "location.contributingAuthor"
+	PropertyLocationOriginalName       = "location.originalName"
+	PropertyLocationBGM                = "location.bgm"
+	PropertyLocationMapIds             = "location.mapIds"
+	PropertyLocationMap                = "location.map"
+	PropertyLocationVersionAdded       = "location.versionAdded"
+	PropertyLocationVersionsUpdated    = "location.versionsUpdated"
+	PropertyLocationVersionRemoved     = "location.versionRemoved"
+	PropertyLocationVersionGaps        = "location.versionGaps"
+
+	PropertyMapId              = "mapId.id"
+	PropertyBGMPath            = "bgm.path"
+	PropertyBGMTitle           = "bgm.title"
+	PropertyBGMLabel           = "bgm.label"
+	PropertyLocationMapPath    = "locationMap.path"
+	PropertyLocationMapCaption = "locationMap.caption"
+
+	PropertySubobjectType               = "subobjectType"
+	PropertyConnectionOrigin            = "connection.origin"
+	PropertyConnectionDestination       = "connection.destination"
+	PropertyConnectionAttribute         = "connection.attribute"
+	PropertyConnectionUnlockConditions  = "connection.unlockConditions"
+	PropertyConnectionEffectsNeeded     = "connection.effectsNeeded"
+	PropertyConnectionSeasonAvailable   = "connection.seasonAvailable"
+	PropertyConnectionChancePercentage  = "connection.chancePercentage"
+	PropertyConnectionChanceDescription = "connection.chanceDescription"
+	PropertyConnectionIsRemoved         = "connection.isRemoved"
+
+	PropertyAuthorName             = "author.name"
+	PropertyAuthorOriginalName     = "author.originalName"
+	PropertyAuthorOriginalNameText = "author.originalNameText"
+
+	PropertyVendingMachinePath          = "vendingMachine.path"
+	PropertyVendingMachineMapId         = "vendingMachine.mapId"
+	PropertyVendingMachineEventId       = "vendingMachine.eventId"
+	PropertyVendingMachineLocation      = "vendingMachine.location"
+	PropertyVendingMachineIsImplemented = "vendingMachine.isImplemented"
+	PropertyVendingMachineIsAccessible  = "vendingMachine.isAccessible"
+	PropertyVendingMachineIsSecret      = "vendingMachine.isSecret"
+)
+
+// defaultProperties maps each field read from Semantic MediaWiki to the
+// property name used on yume.wiki. Games can override any of them.
+var defaultProperties = map[string]string{
+	PropertyLocationImage:              "Has location image",
+	PropertyLocationBackgroundColor:    "Header background color",
+	PropertyLocationFontColor:          "Header font color",
+	PropertyLocationPrimaryAuthor:      "Has primary author",
+	PropertyLocationContributingAuthor: "Has contributing author",
+	PropertyLocationOriginalName:       "Japanese name",
+	PropertyLocationBGM:                "Has BGM",
+	PropertyLocationMapIds:             "Map IDs",
+	PropertyLocationMap:                "Has location map",
+	PropertyLocationVersionAdded:       "Version added",
+	PropertyLocationVersionsUpdated:    "Versions updated",
+	PropertyLocationVersionRemoved:     "Version removed",
+	PropertyLocationVersionGaps:        "Version gaps",
+
+	PropertyMapId:              "Has map ID",
+	PropertyBGMPath:            "Has media path",
+	PropertyBGMTitle:           "BGM/Title",
+	PropertyBGMLabel:           "BGM/Label",
+	PropertyLocationMapPath:    "Has image path",
+	PropertyLocationMapCaption: "Location Map/Caption",
+
+	PropertySubobjectType:               "Is subobject type",
+	PropertyConnectionOrigin:            "Connection/Origin",
+	PropertyConnectionDestination:       "Connection/Location",
+	PropertyConnectionAttribute:         "Connection/Attribute",
+	PropertyConnectionUnlockConditions:  "Connection/Unlock conditions",
+	PropertyConnectionEffectsNeeded:     "Connection/Effects needed",
+	PropertyConnectionSeasonAvailable:   "Connection/Season available",
+	PropertyConnectionChancePercentage:  "Connection/Chance percentage",
+	PropertyConnectionChanceDescription: "Connection/Chance description",
+	PropertyConnectionIsRemoved:         "Connection/Is removed",
+
+	PropertyAuthorName:             "Author/Name",
+	PropertyAuthorOriginalName:     "Author/Original Name",
+	PropertyAuthorOriginalNameText: "Text",
+
+	PropertyVendingMachinePath:          "Has image path",
+	PropertyVendingMachineMapId:         "Vending Machine/Map ID",
+	PropertyVendingMachineEventId:       "Vending Machine/Event ID",
+	PropertyVendingMachineLocation:      "Vending Machine/Location",
+	PropertyVendingMachineIsImplemented: "Vending Machine/Is implemented",
+	PropertyVendingMachineIsAccessible:  "Vending Machine/Is accessible",
+	PropertyVendingMachineIsSecret:      "Vending Machine/Is secret",
+}
+
+// Property returns the SMW property name the game uses for a field.
+func (g Game) Property(key string) string {
+	if property, ok := g.Properties[key]; ok {
+		return property
+	}
+	return defaultProperties[key]
+}
+
+func validateProperties(code string, game Game) error {
+	for key, property := range game.Properties {
+		if _, ok := defaultProperties[key]; !ok {
+			return fmt.Errorf("game %s: unknown property mapping %q", code, key)
+		}
+
+		if strings.TrimSpace(property) == "" {
+			return fmt.Errorf("game %s: property mapping %q is empty", code, key)
+		}
+
+		if strings.ContainsAny(property, "|[]") {
+			return fmt.Errorf("game %s: property mapping %q contains an invalid character", code, key)
+		}
+	}
+
+	return nil
+}
@@ -2,8 +2,10 @@ package setup
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
@@ -12,18 +14,64 @@ type contextKey string
 
 const ConfigKey = contextKey("config")
 
+const (
+	FeatureAuthors         = "authors"
+	FeatureVendingMachines = "vendingMachines"
+	FeatureImages          = "images"
+
+	// FeatureEffects and FeatureMenuThemes are accepted in configs but no
+	// endpoint serves these datasets yet.
+	FeatureEffects    = "effects"
+	FeatureMenuThemes = "menuThemes"
+)
+
+// defaultFeatures are the datasets assumed available for a game when its
+// config does not say otherwise.
+var defaultFeatures = map[string]bool{
+	FeatureAuthors:         false,
+	FeatureVendingMachines: true,
+	FeatureEffects:         false,
+	FeatureMenuThemes:      false,
+	FeatureImages:          true,
+}
+
 type Game struct {
 	Name         string            `yaml:"name"`
 	Namespace    string            `yaml:"namespace"`
 	Protagonists map[string]string `yaml:"protagonists"`
+	Hub          string            `yaml:"hub"`
+	Features     map[string]bool   `yaml:"features"`
+	Properties   map[string]string `yaml:"properties"`
+}
+
+// HasFeature reports whether a dataset is available for the game.
+func (g Game) HasFeature(feature string) bool {
+	if enabled, ok := g.Features[feature]; ok {
+		return enabled
+	}
+	return defaultFeatures[feature]
 }
 
 type Protagonist struct {
 	Category string `yaml:"category"`
 }
 
+// CacheConfig controls how long data fetched from the wiki is reused. When
+// Dir is set, the data is also saved there so it stays available when the
+// wiki cannot be reached.
+type CacheConfig struct {
+	Ttl time.Duration `yaml:"ttl"`
+	Dir string        `yaml:"dir"`
+}
+
 type WikiConfig struct {
-	Games map[string]Game `yaml:"games"`
+	Games         map[string]Game `yaml:"games"`
+	SmwApiVersion int             `yaml:"smwApiVersion"`
+	Cache         CacheConfig     `yaml:"cache"`
+
+	// ConnectionAttributes maps connection attribute values read from the
+	// wiki to normalized attributes, on top of the yume.wiki defaults.
+	ConnectionAttributes map[string]string `yaml:"connectionAttributes"`
 }
 
 func LoadWikiConfig(filename string) (WikiConfig, error) {
@@ -35,8 +83,35 @@ func LoadWikiConfig(filename string) (WikiConfig, error) {
 	}
 
 	err = yaml.Unmarshal(data, &config)
+	if err != nil {
+		return config, err
+	}
+
+	if config.SmwApiVersion != 0 && config.SmwApiVersion != 2 && config.SmwApiVersion != 3 {
+		return config, fmt.Errorf("unsupported smwApiVersion %d (accepted values are 2 and 3)", config.SmwApiVersion)
+	}
+
+	if config.Cache.Ttl < 0 {
+		return config, fmt.Errorf("cache ttl must not be negative")
+	}
+
+	if err := validateConnectionAttributes(&config); err != nil {
+		return config, err
+	}
+
+	for code, game := range config.Games {
+		for feature := range game.Features {
+			if _, ok := defaultFeatures[feature]; !ok {
+				return config, fmt.Errorf("game %s: unknown feature %q", code, feature)
+			}
+		}
+
+		if err := validateProperties(code, game); err != nil {
+			return config, err
+		}
+	}
 
-	return config, err
+	return config, nil
 }
 
 func WikiConfigHandlerMiddleware(config WikiConfig) func(http.Handler) http.Handler {